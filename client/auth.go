@@ -0,0 +1,236 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// AuthOption configures how the requests are authenticated. At most one of the bearer token, the basic auth, the
+// API key and the credential helper can be specified. The static headers are always sent.
+type AuthOption struct {
+	BearerToken string
+
+	// Username and Password are used for the HTTP basic authentication.
+	Username string
+	Password string
+
+	// APIKey is sent in the header named by APIKeyHeader.
+	APIKeyHeader string
+	APIKey       string
+
+	// Headers are the static headers sent along with each request.
+	Headers map[string]string
+
+	// CredentialHelper is the external command whose stdout supplies a bearer token.
+	CredentialHelper *CredentialHelperOption
+}
+
+// CredentialHelperOption configures the external command that supplies the bearer token. The command is run lazily
+// before the first request, and run again whenever the server responds 401, in case the token has expired.
+type CredentialHelperOption struct {
+	Command string
+	Args    []string
+}
+
+func (opt AuthOption) validate() error {
+	var kinds []string
+	if opt.BearerToken != "" {
+		kinds = append(kinds, "bearer token")
+	}
+	if opt.Username != "" || opt.Password != "" {
+		kinds = append(kinds, "basic auth")
+	}
+	if opt.APIKeyHeader != "" || opt.APIKey != "" {
+		if opt.APIKeyHeader == "" {
+			return fmt.Errorf("the header name of the API key is not specified")
+		}
+		kinds = append(kinds, "API key")
+	}
+	if opt.CredentialHelper != nil {
+		if opt.CredentialHelper.Command == "" {
+			return fmt.Errorf("the command of the credential helper is not specified")
+		}
+		kinds = append(kinds, "credential helper")
+	}
+	if len(kinds) > 1 {
+		return fmt.Errorf("only one of %s can be specified", strings.Join(kinds, ", "))
+	}
+	return nil
+}
+
+// authenticator adds the credentials to the requests.
+type authenticator struct {
+	opt AuthOption
+
+	mu sync.Mutex
+	// token is the one got from the credential helper, which is empty until it is requested.
+	token string
+	// refresh is the run of the credential helper in flight, if any, which is shared by the concurrent requests.
+	refresh *helperCall
+}
+
+// helperCall is a run of the credential helper, whose result is set before done is closed.
+type helperCall struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+func newAuthenticator(opt *AuthOption) (*authenticator, error) {
+	if opt == nil {
+		return &authenticator{}, nil
+	}
+	if err := opt.validate(); err != nil {
+		return nil, err
+	}
+	return &authenticator{opt: *opt}, nil
+}
+
+// refreshable tells whether the credential can be refreshed when the server responds 401.
+func (a *authenticator) refreshable() bool {
+	return a.opt.CredentialHelper != nil
+}
+
+// apply adds the credentials to the request, returns the token got from the credential helper, if any.
+func (a *authenticator) apply(ctx context.Context, req *http.Request) (string, error) {
+	for k, v := range a.opt.Headers {
+		req.Header.Set(k, v)
+	}
+	switch {
+	case a.opt.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+a.opt.BearerToken)
+	case a.opt.Username != "" || a.opt.Password != "":
+		req.SetBasicAuth(a.opt.Username, a.opt.Password)
+	case a.opt.APIKeyHeader != "":
+		req.Header.Set(a.opt.APIKeyHeader, a.opt.APIKey)
+	case a.opt.CredentialHelper != nil:
+		token, err := a.helperToken(ctx)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return token, nil
+	}
+	return "", nil
+}
+
+// invalidate discards the token got from the credential helper, so that the next request runs the helper again.
+// It is a no-op if the token has already been refreshed by another request.
+func (a *authenticator) invalidate(token string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token == token {
+		a.token = ""
+	}
+}
+
+// helperToken returns the token got from the credential helper. The helper is run without holding the lock, and its
+// run is shared by the concurrent callers, which stop waiting once their contexts are done.
+func (a *authenticator) helperToken(ctx context.Context) (string, error) {
+	for {
+		a.mu.Lock()
+		if a.token != "" {
+			token := a.token
+			a.mu.Unlock()
+			return token, nil
+		}
+		if call := a.refresh; call != nil {
+			a.mu.Unlock()
+			select {
+			case <-call.done:
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+			if isContextError(call.err) && ctx.Err() == nil {
+				// The context of the caller running the helper is done, while this one is not.
+				continue
+			}
+			return call.token, call.err
+		}
+		call := &helperCall{done: make(chan struct{})}
+		a.refresh = call
+		a.mu.Unlock()
+
+		call.token, call.err = a.runHelper(ctx)
+
+		a.mu.Lock()
+		a.refresh = nil
+		if call.err == nil {
+			a.token = call.token
+		}
+		a.mu.Unlock()
+		close(call.done)
+		return call.token, call.err
+	}
+}
+
+// runHelper runs the credential helper, returns the token printed to its stdout.
+func (a *authenticator) runHelper(ctx context.Context) (string, error) {
+	helper := a.opt.CredentialHelper
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, helper.Command, helper.Args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("running credential helper %q: %v. Stderr: %s", helper.Command, err, a.redactWith(stderr.String(), ""))
+	}
+	token := strings.TrimSpace(stdout.String())
+	if token == "" {
+		return "", fmt.Errorf("credential helper %q returns an empty token", helper.Command)
+	}
+	return token, nil
+}
+
+// redact replaces the credentials in s, e.g. a response body echoing the request headers. The static headers are not
+// redacted, as they are not necessarily secrets.
+func (a *authenticator) redact(s string) string {
+	a.mu.Lock()
+	token := a.token
+	a.mu.Unlock()
+	return a.redactWith(s, token)
+}
+
+func (a *authenticator) redactWith(s string, token string) string {
+	secrets := []string{a.opt.BearerToken, a.opt.Password, a.opt.APIKey, token}
+	if a.opt.Password != "" {
+		secrets = append(secrets, base64.StdEncoding.EncodeToString([]byte(a.opt.Username+":"+a.opt.Password)))
+	}
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		s = strings.ReplaceAll(s, secret, "<redacted>")
+	}
+	return s
+}
+
+// redactError redacts the secrets from the error message, while keeping the error chain.
+func (a *authenticator) redactError(err error) error {
+	msg := a.redact(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{msg: msg, err: err}
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
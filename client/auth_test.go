@@ -0,0 +1,206 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newAuthTestServer returns a server that only serves the requests passing the check. The rejected requests get a
+// 401 response echoing the request headers, to verify that the secrets are redacted from the error.
+func newAuthTestServer(t *testing.T, check func(r *http.Request) bool) *httptest.Server {
+	h := testHandler{
+		buf: map[string]map[string]interface{}{},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !check(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(fmt.Sprintf("unauthorized: %v", r.Header)))
+			return
+		}
+		h.Handle(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestAuth(t *testing.T) {
+	cases := []struct {
+		name  string
+		auth  AuthOption
+		check func(r *http.Request) bool
+	}{
+		{
+			name: "bearer token",
+			auth: AuthOption{BearerToken: "secret-token"},
+			check: func(r *http.Request) bool {
+				return r.Header.Get("Authorization") == "Bearer secret-token"
+			},
+		},
+		{
+			name: "basic auth",
+			auth: AuthOption{Username: "user", Password: "secret-password"},
+			check: func(r *http.Request) bool {
+				username, password, ok := r.BasicAuth()
+				return ok && username == "user" && password == "secret-password"
+			},
+		},
+		{
+			name: "api key",
+			auth: AuthOption{APIKeyHeader: "X-API-Key", APIKey: "secret-key"},
+			check: func(r *http.Request) bool {
+				return r.Header.Get("X-API-Key") == "secret-key"
+			},
+		},
+		{
+			name: "static headers",
+			auth: AuthOption{Headers: map[string]string{"X-Tenant": "secret-tenant"}},
+			check: func(r *http.Request) bool {
+				return r.Header.Get("X-Tenant") == "secret-tenant"
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			ts := newAuthTestServer(t, tt.check)
+
+			c, err := NewJSONServerClient(ts.URL+"/posts", &JSONServerClientOption{Auth: &tt.auth})
+			require.NoError(t, err)
+			id, err := c.Create(ctx, []byte(`{"name": "foo"}`))
+			require.NoError(t, err, "create with credential")
+			_, _, err = c.Read(ctx, id)
+			require.NoError(t, err, "read with credential")
+
+			c, err = NewJSONServerClient(ts.URL+"/posts", nil)
+			require.NoError(t, err)
+			_, err = c.Create(ctx, []byte(`{"name": "foo"}`))
+			require.Error(t, err, "create without credential")
+		})
+	}
+}
+
+func TestAuthRedact(t *testing.T) {
+	ctx := context.Background()
+	ts := newAuthTestServer(t, func(r *http.Request) bool { return false })
+	c, err := NewJSONServerClient(ts.URL+"/posts", &JSONServerClientOption{
+		Auth: &AuthOption{
+			Username: "user",
+			Password: "secret-password",
+			Headers:  map[string]string{"Accept": "application/json"},
+		},
+	})
+	require.NoError(t, err)
+	_, err = c.Create(ctx, []byte(`{"name": "foo"}`))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "<redacted>")
+	require.Contains(t, err.Error(), "application/json", "the static headers are not secrets")
+	require.NotContains(t, err.Error(), "dXNlcjpzZWNyZXQtcGFzc3dvcmQ=", "base64 encoded basic auth")
+}
+
+func TestAuthCredentialHelper(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	ctx := context.Background()
+
+	// The helper prints a new token each time it runs, while only the second one is accepted by the server.
+	counter := filepath.Join(t.TempDir(), "counter")
+	script := fmt.Sprintf(`n=$(cat %[1]q 2>/dev/null || echo 0); n=$((n+1)); echo $n > %[1]q; echo token-$n`, counter)
+	var unauthorized int
+	ts := newAuthTestServer(t, func(r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			unauthorized++
+			return false
+		}
+		return true
+	})
+	c, err := NewJSONServerClient(ts.URL+"/posts", &JSONServerClientOption{
+		Auth: &AuthOption{
+			CredentialHelper: &CredentialHelperOption{Command: "sh", Args: []string{"-c", script}},
+		},
+	})
+	require.NoError(t, err)
+
+	id, err := c.Create(ctx, []byte(`{"name": "foo"}`))
+	require.NoError(t, err, "create with the refreshed token")
+	require.Equal(t, 1, unauthorized, "the first token is rejected")
+	_, _, err = c.Read(ctx, id)
+	require.NoError(t, err, "read with the cached token")
+	require.Equal(t, 1, unauthorized, "the refreshed token is cached")
+
+	c, err = NewJSONServerClient(ts.URL+"/posts", &JSONServerClientOption{
+		Auth: &AuthOption{
+			CredentialHelper: &CredentialHelperOption{Command: "sh", Args: []string{"-c", "exit 1"}},
+		},
+	})
+	require.NoError(t, err)
+	_, err = c.Create(ctx, []byte(`{"name": "foo"}`))
+	require.ErrorContains(t, err, "credential helper", "failed credential helper")
+}
+
+func TestAuthCredentialHelperConcurrent(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+	// The helper blocks until the release file is created, and counts its runs.
+	dir := t.TempDir()
+	counter, release := filepath.Join(dir, "counter"), filepath.Join(dir, "release")
+	script := fmt.Sprintf(`echo x >> %q; while [ ! -f %q ]; do sleep 0.01; done; echo token`, counter, release)
+	a, err := newAuthenticator(&AuthOption{
+		CredentialHelper: &CredentialHelperOption{Command: "sh", Args: []string{"-c", script}},
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	results := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		go func() {
+			token, err := a.helperToken(ctx)
+			if err == nil && token != "token" {
+				err = fmt.Errorf("unexpected token %q", token)
+			}
+			results <- err
+		}()
+	}
+	require.Eventually(t, func() bool {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		return a.refresh != nil
+	}, 5*time.Second, time.Millisecond)
+
+	// Neither the redaction nor the caller whose context is done waits for the helper.
+	require.Equal(t, "foo", a.redact("foo"))
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = a.helperToken(canceled)
+	require.ErrorIs(t, err, context.Canceled)
+
+	require.NoError(t, os.WriteFile(release, nil, 0644))
+	for i := 0; i < 5; i++ {
+		require.NoError(t, <-results)
+	}
+	b, err := os.ReadFile(counter)
+	require.NoError(t, err)
+	require.Equal(t, "x\n", string(b), "the helper is run once")
+}
+
+func TestAuthInvalidOption(t *testing.T) {
+	for _, opt := range []AuthOption{
+		{BearerToken: "token", Username: "user", Password: "password"},
+		{APIKey: "key"},
+		{CredentialHelper: &CredentialHelperOption{}},
+	} {
+		opt := opt
+		_, err := NewJSONServerClient("http://localhost/posts", &JSONServerClientOption{Auth: &opt})
+		require.Error(t, err, "%#v", opt)
+	}
+}
@@ -0,0 +1,225 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// CacheOption configures the read-through cache of the client.
+type CacheOption struct {
+	// PrefetchThreshold is the number of the reads missing the cache, after which the whole collection is loaded into
+	// the cache by a single List, which happens at most once. Zero means no prefetch.
+	// The resources listed without the versions, e.g. by the JSONServerClient, are not prefetched but still read one by
+	// one, so that the writes based on the reads remain conditional.
+	PrefetchThreshold int
+}
+
+// CacheMiddleware caches the resources read from the client for the lifetime of the client, which is a single run of
+// the provider. The concurrent reads of the same resource are coalesced into one, and the resource is invalidated
+// once it is written, including the Restore of the HistoryClient, no matter the write succeeds or not. The writes
+// bypassing the middleware, e.g. by the others, are not seen.
+func CacheMiddleware(opt CacheOption) Middleware {
+	return func(c Client) Client {
+		cc := &cachedClient{
+			next:      c,
+			threshold: opt.PrefetchThreshold,
+			entries:   map[string]cacheEntry{},
+			reads:     map[string]*cacheCall{},
+		}
+		if hc, ok := c.(HistoryClient); ok {
+			return &cachedHistoryClient{cachedClient: cc, history: hc}
+		}
+		return cc
+	}
+}
+
+type cacheEntry struct {
+	b       []byte
+	version string
+}
+
+// cacheCall is the call to the client shared by the concurrent callers, which wait until done is closed.
+type cacheCall struct {
+	done chan struct{}
+	cacheEntry
+	err error
+}
+
+func newCacheCall() *cacheCall {
+	return &cacheCall{done: make(chan struct{})}
+}
+
+// wait waits for the call to be done, until the context is done.
+func (c *cacheCall) wait(ctx context.Context) error {
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type cachedClient struct {
+	next      Client
+	threshold int
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	// reads are the reads in flight, by the id.
+	reads map[string]*cacheCall
+	// prefetch is the prefetch in flight, if any.
+	prefetch *cacheCall
+	// prefetched tells whether the prefetch has been done.
+	prefetched bool
+	misses     int
+	// generation is increased by each write, so that the result of the read or the prefetch started before the write
+	// is not cached.
+	generation uint64
+}
+
+func (c *cachedClient) Unwrap() Client {
+	return c.next
+}
+
+func (c *cachedClient) Create(ctx context.Context, b []byte) (string, error) {
+	// The new resource is not cached yet, it is read back from the client.
+	return c.next.Create(ctx, b)
+}
+
+func (c *cachedClient) Read(ctx context.Context, id string) ([]byte, string, error) {
+	for {
+		c.mu.Lock()
+		if entry, ok := c.entries[id]; ok {
+			c.mu.Unlock()
+			return copyBytes(entry.b), entry.version, nil
+		}
+		if call := c.prefetch; call != nil {
+			c.mu.Unlock()
+			if err := call.wait(ctx); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+		if call, ok := c.reads[id]; ok {
+			c.mu.Unlock()
+			if err := call.wait(ctx); err != nil {
+				return nil, "", err
+			}
+			if isContextError(call.err) && ctx.Err() == nil {
+				// The context of the caller doing the read is done, while this one is not.
+				continue
+			}
+			if call.err != nil {
+				return nil, "", call.err
+			}
+			return copyBytes(call.b), call.version, nil
+		}
+		c.misses++
+		if c.threshold > 0 && !c.prefetched && c.misses >= c.threshold {
+			c.prefetched = true
+			c.prefetch = newCacheCall()
+			c.mu.Unlock()
+			c.load(ctx)
+			continue
+		}
+		call := newCacheCall()
+		c.reads[id] = call
+		generation := c.generation
+		c.mu.Unlock()
+
+		call.b, call.version, call.err = c.next.Read(ctx, id)
+
+		c.mu.Lock()
+		if c.reads[id] == call {
+			delete(c.reads, id)
+		}
+		if call.err == nil && c.generation == generation {
+			c.entries[id] = cacheEntry{b: copyBytes(call.b), version: call.version}
+		}
+		c.mu.Unlock()
+		close(call.done)
+		if call.err != nil {
+			return nil, "", call.err
+		}
+		return copyBytes(call.b), call.version, nil
+	}
+}
+
+// load loads the whole collection into the cache by the List, except the resources listed without the version. The
+// failure is not reported, as the reads fall back to read the resources one by one.
+func (c *cachedClient) load(ctx context.Context) {
+	c.mu.Lock()
+	call, generation := c.prefetch, c.generation
+	c.mu.Unlock()
+
+	objects, err := c.next.List(ctx, true)
+
+	c.mu.Lock()
+	c.prefetch = nil
+	if isContextError(err) {
+		// Let the others try again.
+		c.prefetched = false
+		c.misses = 0
+	}
+	if err == nil && c.generation == generation {
+		for _, obj := range objects {
+			if obj.Version == "" {
+				continue
+			}
+			c.entries[obj.ID] = cacheEntry{b: copyBytes(obj.Content), version: obj.Version}
+		}
+	}
+	c.mu.Unlock()
+	close(call.done)
+}
+
+// invalidate removes the resource from the cache, and makes the reads in flight not cached.
+func (c *cachedClient) invalidate(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	delete(c.entries, id)
+	// The later reads don't wait for the one started before the write.
+	delete(c.reads, id)
+}
+
+func (c *cachedClient) Update(ctx context.Context, id string, b []byte, version string) error {
+	defer c.invalidate(id)
+	return c.next.Update(ctx, id, b, version)
+}
+
+func (c *cachedClient) Patch(ctx context.Context, id string, patch []byte, version string) error {
+	defer c.invalidate(id)
+	return c.next.Patch(ctx, id, patch, version)
+}
+
+func (c *cachedClient) Delete(ctx context.Context, id string, version string) error {
+	defer c.invalidate(id)
+	return c.next.Delete(ctx, id, version)
+}
+
+func (c *cachedClient) List(ctx context.Context, withContent bool) ([]Object, error) {
+	return c.next.List(ctx, withContent)
+}
+
+// cachedHistoryClient is the cachedClient of the client implementing the HistoryClient.
+type cachedHistoryClient struct {
+	*cachedClient
+	history HistoryClient
+}
+
+var _ HistoryClient = &cachedHistoryClient{}
+
+func (c *cachedHistoryClient) History(ctx context.Context, id string) ([]Revision, error) {
+	return c.history.History(ctx, id)
+}
+
+func (c *cachedHistoryClient) Restore(ctx context.Context, id string, version string) error {
+	defer c.invalidate(id)
+	return c.history.Restore(ctx, id, version)
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
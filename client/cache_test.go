@@ -0,0 +1,245 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// countingMiddleware counts the operations reaching the inner client, by the name.
+func countingMiddleware(mu *sync.Mutex, counts map[string]int) Middleware {
+	return Intercept(func(ctx context.Context, op *Operation, next func(ctx context.Context) error) error {
+		mu.Lock()
+		counts[op.Name]++
+		mu.Unlock()
+		return next(ctx)
+	})
+}
+
+func TestCacheMiddleware(t *testing.T) {
+	ctx := context.Background()
+	var mu sync.Mutex
+	counts := map[string]int{}
+	inner := NewMemoryClient()
+	c := Chain(inner, CacheMiddleware(CacheOption{}), countingMiddleware(&mu, counts))
+
+	id, err := c.Create(ctx, []byte(`{"a": 1}`))
+	require.NoError(t, err)
+	b, version, err := c.Read(ctx, id)
+	require.NoError(t, err)
+	b[0] = 'x'
+	b, cachedVersion, err := c.Read(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, `{"a": 1}`, string(b), "the cached content is not modified by the caller")
+	require.Equal(t, version, cachedVersion)
+	require.Equal(t, 1, counts["Read"])
+
+	require.NoError(t, c.Update(ctx, id, []byte(`{"a": 2}`), version))
+	b, _, err = c.Read(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, `{"a": 2}`, string(b), "the update invalidates the cache")
+	require.Equal(t, 2, counts["Read"])
+
+	// The failed write also invalidates the cache, as the resource might have been changed by the others.
+	require.NoError(t, inner.Update(ctx, id, []byte(`{"a": 3}`), ""))
+	require.ErrorIs(t, c.Patch(ctx, id, []byte(`{"b": 1}`), version), ErrConflict)
+	b, _, err = c.Read(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, `{"a": 3}`, string(b))
+
+	require.NoError(t, c.Delete(ctx, id, ""))
+	_, _, err = c.Read(ctx, id)
+	require.ErrorIs(t, err, ErrNotFound)
+	_, _, err = c.Read(ctx, id)
+	require.ErrorIs(t, err, ErrNotFound)
+	require.Equal(t, 5, counts["Read"], "the missing resource is not cached")
+	require.Same(t, inner, Unwrap(c))
+}
+
+func TestCacheMiddlewareRestore(t *testing.T) {
+	ctx := context.Background()
+	var mu sync.Mutex
+	counts := map[string]int{}
+	inner, err := newFsClient(afero.NewMemMapFs(), "/tmp", &FsClientOption{HistoryLimit: 2})
+	require.NoError(t, err)
+	c := Chain(inner, CacheMiddleware(CacheOption{}), countingMiddleware(&mu, counts))
+	hc, ok := c.(HistoryClient)
+	require.True(t, ok, "the history client is forwarded")
+
+	id, err := c.Create(ctx, []byte(`{"v": 1}`))
+	require.NoError(t, err)
+	require.NoError(t, c.Update(ctx, id, []byte(`{"v": 2}`), ""))
+	b, _, err := c.Read(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, `{"v": 2}`, string(b))
+
+	revs, err := hc.History(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, revs, 1)
+	require.NoError(t, hc.Restore(ctx, id, revs[0].Version))
+	b, _, err = c.Read(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, `{"v": 1}`, string(b), "the restore invalidates the cache")
+	require.Equal(t, 2, counts["Read"])
+	require.Equal(t, 1, counts["Restore"])
+}
+
+func TestCacheMiddlewareCoalesce(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMemoryClient()
+	id, err := inner.Create(ctx, []byte(`{}`))
+	require.NoError(t, err)
+
+	var (
+		mu    sync.Mutex
+		reads int
+	)
+	release := make(chan struct{})
+	gate := Intercept(func(ctx context.Context, op *Operation, next func(ctx context.Context) error) error {
+		mu.Lock()
+		reads++
+		mu.Unlock()
+		<-release
+		return next(ctx)
+	})
+	c := Chain(inner, CacheMiddleware(CacheOption{}), gate)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b, _, err := c.Read(ctx, id)
+			require.NoError(t, err)
+			require.Equal(t, `{}`, string(b))
+		}()
+	}
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return reads == 1
+	}, time.Second, time.Millisecond)
+	cached := c.(*cachedClient)
+	require.Eventually(t, func() bool {
+		cached.mu.Lock()
+		defer cached.mu.Unlock()
+		return len(cached.reads) == 1
+	}, time.Second, time.Millisecond)
+	// Give the readers time to join the read in flight.
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	require.Equal(t, 1, reads)
+}
+
+func TestCacheMiddlewareCanceled(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMemoryClient()
+	id, err := inner.Create(ctx, []byte(`{}`))
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	first := true
+	gate := Intercept(func(ctx context.Context, op *Operation, next func(ctx context.Context) error) error {
+		if first {
+			first = false
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return next(ctx)
+	})
+	c := Chain(inner, CacheMiddleware(CacheOption{}), gate)
+
+	canceled, cancel := context.WithCancel(ctx)
+	errc := make(chan error)
+	go func() {
+		_, _, err := c.Read(canceled, id)
+		errc <- err
+	}()
+	<-started
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// The read in flight is canceled by its caller, the waiting one reads again by itself.
+		b, _, err := c.Read(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, `{}`, string(b))
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	require.ErrorIs(t, <-errc, context.Canceled)
+	<-done
+}
+
+func TestCacheMiddlewarePrefetch(t *testing.T) {
+	ctx := context.Background()
+	var mu sync.Mutex
+	counts := map[string]int{}
+	inner := NewMemoryClient()
+	var ids []string
+	for i := 0; i < 5; i++ {
+		id, err := inner.Create(ctx, []byte(`{}`))
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	c := Chain(inner, CacheMiddleware(CacheOption{PrefetchThreshold: 2}), countingMiddleware(&mu, counts))
+
+	for _, id := range ids {
+		b, version, err := c.Read(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, `{}`, string(b))
+		require.Equal(t, contentVersion(b), version, "the version is got from the List")
+	}
+	require.Equal(t, map[string]int{"Read": 1, "List": 1}, counts)
+
+	// The resource created later is read from the client, while the prefetch isn't repeated.
+	id, err := c.Create(ctx, []byte(`{}`))
+	require.NoError(t, err)
+	_, _, err = c.Read(ctx, id)
+	require.NoError(t, err)
+	_, _, err = c.Read(ctx, "x")
+	require.ErrorIs(t, err, ErrNotFound)
+	require.Equal(t, map[string]int{"Create": 1, "Read": 3, "List": 1}, counts)
+}
+
+func TestCacheMiddlewarePrefetchWithoutVersion(t *testing.T) {
+	ctx := context.Background()
+	h := testHandler{
+		buf: map[string]map[string]interface{}{},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(h.Handle))
+	defer ts.Close()
+	inner, err := NewJSONServerClient(ts.URL+"/posts", nil)
+	require.NoError(t, err)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		id, err := inner.Create(ctx, []byte(`{"name": "foo"}`))
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	var mu sync.Mutex
+	counts := map[string]int{}
+	c := Chain(inner, CacheMiddleware(CacheOption{PrefetchThreshold: 1}), countingMiddleware(&mu, counts))
+
+	// The JSONServerClient lists the resources without the versions, which are read one by one instead.
+	versions := map[string]string{}
+	for _, id := range ids {
+		_, version, err := c.Read(ctx, id)
+		require.NoError(t, err)
+		require.NotEmpty(t, version)
+		versions[id] = version
+	}
+	require.Equal(t, map[string]int{"Read": 3, "List": 1}, counts)
+
+	// The patch based on the read is still conditional.
+	require.NoError(t, inner.Update(ctx, ids[0], []byte(`{"name": "bar"}`), ""))
+	require.ErrorIs(t, c.Patch(ctx, ids[0], []byte(`{"age": 1}`), versions[ids[0]]), ErrConflict)
+	require.NoError(t, c.Patch(ctx, ids[1], []byte(`{"age": 1}`), versions[ids[1]]))
+}
@@ -1,13 +1,70 @@
 package client
 
-import "errors"
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
 
-// ErrNotFound is expected to be returned for `Read` when the resource with the specified id doesn't exist.
-var ErrNotFound = errors.New("resource not found")
+// Object is a stored resource returned by `List`.
+type Object struct {
+	ID string
+	// Content is only populated when `List` is called with `withContent` set to true.
+	Content []byte
+	// Version is the version of the content, as returned by `Read`. It is only populated together with the content,
+	// by the clients that know it from the listing, and is empty otherwise.
+	Version string
+}
 
 type Client interface {
-	Create(b []byte) (id string, err error)
-	Read(id string) ([]byte, error)
-	Update(id string, b []byte) error
-	Delete(id string) error
+	Create(ctx context.Context, b []byte) (id string, err error)
+	// Read returns the content of the resource, together with an opaque version token of it.
+	Read(ctx context.Context, id string) (b []byte, version string, err error)
+	// Update updates the resource. If version is not empty, the update only happens when it matches the
+	// resource's current version, otherwise ErrConflict is returned.
+	Update(ctx context.Context, id string, b []byte, version string) error
+	// Patch applies the RFC 7386 JSON merge patch to the resource. The version works the same as for `Update`.
+	Patch(ctx context.Context, id string, patch []byte, version string) error
+	// Delete deletes the resource. If version is not empty, the deletion only happens when it matches the
+	// resource's current version, otherwise ErrConflict is returned.
+	Delete(ctx context.Context, id string, version string) error
+	// List returns all the stored resources, optionally together with their content.
+	List(ctx context.Context, withContent bool) ([]Object, error)
+}
+
+// Revision is a prior version of a resource.
+type Revision struct {
+	// Version is the version of the resource at that time, as returned by `Read`.
+	Version string
+	// Timestamp is when the revision is superseded, either by an update or by the deletion.
+	Timestamp time.Time
+	Content   []byte
+	// Deleted tells whether the resource has been deleted, and the revision is kept in the trash.
+	Deleted bool
+}
+
+// HistoryClient is implemented by the clients keeping the prior versions of the resources.
+type HistoryClient interface {
+	// History returns the prior versions of the resource, the newest first. The ones of the deleted resource are
+	// also returned, as long as they are still kept in the trash.
+	History(ctx context.Context, id string) ([]Revision, error)
+	// Restore restores the resource, which might have been deleted, to the prior version.
+	Restore(ctx context.Context, id string, version string) error
+}
+
+// CollectionClient is implemented by the clients able to keep several collections of the resources apart, e.g. one
+// for each resource type.
+type CollectionClient interface {
+	// Collection returns the client of the named collection, which shares the connections, the locks, etc. with this
+	// client.
+	Collection(name string) (Client, error)
+}
+
+// checkCollectionName checks that the collection name can be used as a single path segment, which is not hidden.
+func checkCollectionName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid collection name %q", name)
+	}
+	return nil
 }
@@ -1,56 +1,746 @@
 package client
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"hash/fnv"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/go-uuid"
 	"github.com/spf13/afero"
 )
 
+// tmpFilePrefix is the prefix of the temporary files, which are written and then renamed to the resource files.
+const tmpFilePrefix = ".tmp-"
+
+// lockDir is the directory under the workdir holding the lock files.
+const lockDir = ".locks"
+
+// storeLockName is the name of the store level lock. It is held in shared mode by the operations on a single
+// resource, and in exclusive mode by the operations on the whole store, e.g. the recovery.
+const storeLockName = ".store"
+
+// resourceLockShards is the number of the resource locks. The resources are locked by the shards of their ids, so that
+// the number of the lock files is bounded, no matter how many resources are ever created, as the lock files can't be
+// safely removed.
+const resourceLockShards = 256
+
+// resourceLockName returns the name of the lock of the resource, which is shared by the resources of the same shard.
+func resourceLockName(id string) string {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return fmt.Sprintf("shard-%02x", h.Sum32()%resourceLockShards)
+}
+
 type FsClient struct {
-	fs  afero.Fs
-	dir string
+	fs          afero.Fs
+	dir         string
+	locker      locker
+	lockTimeout time.Duration
+
+	extension   string
+	shardLength int
+	pretty      bool
+	fileMode    os.FileMode
+	dirMode     os.FileMode
+
+	// typ is the collection, whose resource files are stored under the subdirectory of the same name.
+	typ string
+	// legacyCollection is the collection owning the resource files in the flat workdir.
+	legacyCollection string
+
+	compress  bool
+	encryptor *encryptor
+
+	historyLimit   int
+	trashRetention time.Duration
+
+	wire *wireLogger
+}
+
+type FsClientOption struct {
+	// LockTimeout is the maximum time to wait for acquiring a lock. Zero means waiting until the context is done.
+	LockTimeout time.Duration
+
+	// Extension is the file extension of the resource files, e.g. ".json". Defaults to no extension.
+	Extension string
+	// ShardLength is the length of the id prefix, which is used as the subdirectory to store the resource file.
+	// Zero means no sharding.
+	ShardLength int
+	// LegacyCollection is the collection owning the resource files in the flat workdir, i.e. the ones written before
+	// the collections are used. Only that collection looks them up besides the client itself, and moves them under
+	// its subdirectory once written. Defaults to none.
+	LegacyCollection string
+	// Pretty makes the resource files written as the canonical pretty-printed JSON, with the keys sorted.
+	Pretty bool
+	// FileMode is the permission of the resource files. Defaults to 0644.
+	FileMode os.FileMode
+	// DirMode is the permission of the directories. Defaults to 0755.
+	DirMode os.FileMode
+
+	// Compress makes the resource files compressed by gzip. The resource files are read no matter they are compressed
+	// or not.
+	Compress bool
+
+	// HistoryLimit is the number of the prior versions kept for each resource. Zero means no history.
+	HistoryLimit int
+	// TrashRetention is how long the deleted resources are kept in the trash, together with their history. They are
+	// purged once the client is created after the retention. Zero means the resources are deleted permanently.
+	TrashRetention time.Duration
+
+	// Encryption enables the encryption at rest if not nil. The resource files written before the encryption is
+	// enabled are still readable, and get encrypted once written.
+	Encryption *EncryptionOption
+
+	// WireLog configures the redaction of the file contents logged to the wire log subsystem. Nil means the default
+	// redaction.
+	WireLog *WireLogOption
+}
+
+func NewFsClient(dir string, opt *FsClientOption) (Client, error) {
+	f, err := newFsClient(afero.NewOsFs(), dir, opt)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func newFsClient(fs afero.Fs, dir string, opt *FsClientOption) (*FsClient, error) {
+	if opt == nil {
+		opt = &FsClientOption{}
+	}
+	if opt.ShardLength < 0 {
+		return nil, fmt.Errorf("invalid shard length %d", opt.ShardLength)
+	}
+	if opt.HistoryLimit < 0 {
+		return nil, fmt.Errorf("invalid history limit %d", opt.HistoryLimit)
+	}
+	if opt.LegacyCollection != "" {
+		if err := checkCollectionName(opt.LegacyCollection); err != nil {
+			return nil, err
+		}
+	}
+	fileMode, dirMode := opt.FileMode, opt.DirMode
+	if fileMode == 0 {
+		fileMode = 0644
+	}
+	if dirMode == 0 {
+		dirMode = 0755
+	}
+	var enc *encryptor
+	if opt.Encryption != nil {
+		var err error
+		if enc, err = newEncryptor(opt.Encryption); err != nil {
+			return nil, err
+		}
+	}
+	if err := fs.MkdirAll(dir, dirMode); err != nil {
+		return nil, err
+	}
+	// The flock based locker only works for the OS filesystem.
+	var lk locker = newMemLocker()
+	if _, ok := fs.(*afero.OsFs); ok {
+		var err error
+		if lk, err = newOsLocker(filepath.Join(dir, lockDir)); err != nil {
+			return nil, err
+		}
+	}
+	f := &FsClient{
+		fs:          fs,
+		dir:         dir,
+		locker:      lk,
+		lockTimeout: opt.LockTimeout,
+		extension:   opt.Extension,
+		shardLength: opt.ShardLength,
+		pretty:      opt.Pretty,
+		fileMode:    fileMode,
+		dirMode:     dirMode,
+		compress:    opt.Compress,
+		encryptor:   enc,
+
+		legacyCollection: opt.LegacyCollection,
+
+		historyLimit:   opt.HistoryLimit,
+		trashRetention: opt.TrashRetention,
+
+		wire: newWireLogger(opt.WireLog),
+	}
+	if err := f.recover(context.Background()); err != nil {
+		return nil, fmt.Errorf("recovering %s: %v", dir, err)
+	}
+	return f, nil
+}
+
+var _ CollectionClient = &FsClient{}
+
+// Collection returns the client of the collection stored under the subdirectory of the workdir. The resource files
+// in the flat workdir are only looked up by the LegacyCollection.
+func (f *FsClient) Collection(name string) (Client, error) {
+	if err := checkCollectionName(name); err != nil {
+		return nil, err
+	}
+	c := *f
+	c.typ = name
+	// The trash is kept per collection.
+	if err := c.recover(context.Background()); err != nil {
+		return nil, fmt.Errorf("recovering %s: %v", filepath.Join(c.dir, name), err)
+	}
+	return &c, nil
+}
+
+// lock acquires the store lock in shared mode, and then the lock of the resource (i.e. its shard) if id is not empty.
+func (f *FsClient) lock(ctx context.Context, id string, exclusive bool) (func(), error) {
+	unlockStore, err := f.acquire(ctx, storeLockName, false)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return unlockStore, nil
+	}
+	unlock, err := f.acquire(ctx, resourceLockName(id), exclusive)
+	if err != nil {
+		unlockStore()
+		return nil, err
+	}
+	return func() {
+		unlock()
+		unlockStore()
+	}, nil
 }
 
-func NewFsClient(dir string) (Client, error) {
-	return &FsClient{fs: afero.NewOsFs(), dir: dir}, os.MkdirAll(dir, 0755)
+// acquire acquires a single lock, within the lock timeout.
+func (f *FsClient) acquire(ctx context.Context, name string, exclusive bool) (func(), error) {
+	return acquireLock(ctx, f.locker, name, exclusive, f.lockTimeout)
 }
 
-func (f *FsClient) Create(b []byte) (string, error) {
-	// We should check duplication of the generated filename (i.e. the UUID) in the directory.
-	// In fact we shall use the os.CreateTemp() instead. However, since we are also using the afero
-	// to make the UT less dependent to the OS, and afero.Fs doesn't implemented the CreateTemp().
-	// For the sake of simplicity, we choose to use the UUID here for now.
-	id, err := uuid.GenerateUUID()
+// recover cleans up the temporary files left over by the interrupted writes, and purges the expired trash. It holds the
+// store lock exclusively, so that the temporary files being written by the others are not touched.
+func (f *FsClient) recover(ctx context.Context) error {
+	unlock, err := f.acquire(ctx, storeLockName, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	// The temporary files are written next to the resource files, which might be in the subdirectories.
+	err = afero.Walk(f.fs, f.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			// The workdir might be a git working tree, see the GitClient.
+			if info.Name() == lockDir || info.Name() == gitDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasPrefix(info.Name(), tmpFilePrefix) {
+			return nil
+		}
+		if err := f.fs.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return f.purgeTrash()
+}
+
+// path returns the path of the resource file under the configured layout.
+func (f *FsClient) path(id string) string {
+	dir := filepath.Join(f.dir, f.typ)
+	if f.shardLength > 0 && len(id) > f.shardLength {
+		dir = filepath.Join(dir, id[:f.shardLength])
+	}
+	return filepath.Join(dir, id+f.extension)
+}
+
+// logFile logs the operation on the resource file, or on the directory of the resources if id is empty, to the wire
+// log subsystem.
+func (f *FsClient) logFile(ctx context.Context, operation, id string, start time.Time, in, out []byte, err error) {
+	p := filepath.Join(f.dir, f.typ)
+	if id != "" {
+		p = f.path(id)
+	}
+	f.wire.logFile(ctx, operation, p, in, out, time.Since(start), err)
+}
+
+// ownsFlat tells whether the resource files in the flat workdir belong to the client, i.e. it is not a collection, or
+// is the LegacyCollection.
+func (f *FsClient) ownsFlat() bool {
+	return f.typ == "" || f.typ == f.legacyCollection
+}
+
+// locate returns the path of the existing resource file. Besides the configured layout, the resource file is also
+// looked up in the flat workdir, where the resources are stored without any layout option, if the client owns them.
+func (f *FsClient) locate(id string) (string, error) {
+	paths := []string{f.path(id)}
+	if legacy := filepath.Join(f.dir, id); legacy != paths[0] && f.ownsFlat() {
+		paths = append(paths, legacy)
+	}
+	for _, p := range paths {
+		info, err := f.fs.Stat(p)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.IsDir() {
+			continue
+		}
+		return p, nil
+	}
+	return "", ErrNotFound
+}
+
+func (f *FsClient) Create(ctx context.Context, b []byte) (id string, err error) {
+	start := time.Now()
+	defer func() { f.logFile(ctx, "Create", id, start, b, nil, err) }()
+	// The generated filename (i.e. the UUID) is not expected to be duplicated, while we still check it in case.
+	id, err = uuid.GenerateUUID()
 	if err != nil {
 		return "", err
 	}
-	file, err := f.fs.Create(filepath.Join(f.dir, id))
+	unlock, err := f.lock(ctx, id, true)
 	if err != nil {
 		return "", err
 	}
-	defer file.Close()
-	return id, f.Update(id, b)
+	defer unlock()
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if _, err := f.locate(id); err != ErrNotFound {
+		if err == nil {
+			err = fmt.Errorf("resource %s already exists", id)
+		}
+		return "", err
+	}
+	// The resource file only appears once its content is completely written.
+	return id, f.writeFile(id, b)
 }
 
-func (f *FsClient) Update(id string, b []byte) error {
-	return afero.WriteFile(f.fs, filepath.Join(f.dir, id), b, 0666)
+func (f *FsClient) Update(ctx context.Context, id string, b []byte, version string) (err error) {
+	start := time.Now()
+	defer func() { f.logFile(ctx, "Update", id, start, b, nil, err) }()
+	unlock, err := f.lock(ctx, id, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := f.checkVersion(id, version); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := f.archive(id, f.historyLimit); err != nil {
+		return err
+	}
+	return f.writeFile(id, b)
 }
 
-func (f *FsClient) Read(id string) ([]byte, error) {
-	b, err := afero.ReadFile(f.fs, filepath.Join(f.dir, id))
-	if errors.Is(err, os.ErrNotExist) {
-		return nil, ErrNotFound
+func (f *FsClient) Patch(ctx context.Context, id string, patch []byte, version string) (err error) {
+	start := time.Now()
+	defer func() { f.logFile(ctx, "Patch", id, start, patch, nil, err) }()
+	unlock, err := f.lock(ctx, id, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	b, current, err := f.read(id)
+	if err != nil {
+		return err
 	}
-	return b, err
+	if version != "" && version != current {
+		return ErrConflict
+	}
+	b, err = MergePatch(b, patch)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := f.archive(id, f.historyLimit); err != nil {
+		return err
+	}
+	return f.writeFile(id, b)
 }
 
-func (f *FsClient) Delete(id string) error {
-	err := f.fs.Remove(filepath.Join(f.dir, id))
+func (f *FsClient) Read(ctx context.Context, id string) (b []byte, version string, err error) {
+	start := time.Now()
+	defer func() { f.logFile(ctx, "Read", id, start, nil, b, err) }()
+	unlock, err := f.lock(ctx, id, false)
+	if err != nil {
+		return nil, "", err
+	}
+	defer unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	return f.read(id)
+}
+
+// read reads the resource without locking.
+func (f *FsClient) read(id string) ([]byte, string, error) {
+	p, err := f.locate(id)
+	if err != nil {
+		return nil, "", err
+	}
+	b, err := afero.ReadFile(f.fs, p)
 	if errors.Is(err, os.ErrNotExist) {
-		return ErrNotFound
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if b, err = f.decode(b); err != nil {
+		return nil, "", fmt.Errorf("reading resource %s: %w", id, err)
+	}
+	// The version is the hash of the plaintext, which doesn't change by the encryption, the compression or the key
+	// rotation.
+	return b, contentVersion(b), nil
+}
+
+func (f *FsClient) Delete(ctx context.Context, id string, version string) (err error) {
+	start := time.Now()
+	defer func() { f.logFile(ctx, "Delete", id, start, nil, nil, err) }()
+	unlock, err := f.lock(ctx, id, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := f.checkVersion(id, version); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return f.remove(id)
+}
+
+func (f *FsClient) List(ctx context.Context, withContent bool) (objects []Object, err error) {
+	start := time.Now()
+	defer func() { f.logFile(ctx, "List", "", start, nil, nil, err) }()
+	unlock, err := f.lock(ctx, "", false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ids, err := f.listIDs()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		obj := Object{ID: id}
+		if withContent {
+			b, version, err := f.readLocked(ctx, obj.ID)
+			if err != nil {
+				// The resource might be deleted in between.
+				if err == ErrNotFound {
+					continue
+				}
+				return nil, err
+			}
+			obj.Content, obj.Version = b, version
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// RotateKey re-encrypts all the resources that are not encrypted by the current key, including the ones not encrypted
+// at all. The prior versions kept in the history and the trash are re-encrypted as well. The keys used by the
+// existing resources are expected to be configured as the previous keys.
+func (f *FsClient) RotateKey(ctx context.Context) error {
+	if f.encryptor == nil {
+		return errors.New("no encryption key is configured")
+	}
+	unlock, err := f.lock(ctx, "", false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	ids, err := f.listIDs()
+	if err != nil {
+		return err
+	}
+	// The deleted resources only exist in the trash.
+	trash, err := afero.ReadDir(f.fs, filepath.Join(f.dir, f.typ, trashDir))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for _, entry := range trash {
+		if entry.IsDir() {
+			ids = append(ids, entry.Name())
+		}
+	}
+	for _, id := range ids {
+		if err := f.rotateKey(ctx, id); err != nil {
+			return fmt.Errorf("rotating the key of resource %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// rotateKey re-encrypts a single resource, given the store lock is already held.
+func (f *FsClient) rotateKey(ctx context.Context, id string) error {
+	unlock, err := f.acquire(ctx, resourceLockName(id), true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	for _, base := range []string{historyDir, trashDir} {
+		revs, err := f.revisions(base, id)
+		if err != nil {
+			return err
+		}
+		for _, rev := range revs {
+			if err := f.reencrypt(rev.path); err != nil {
+				return err
+			}
+		}
+	}
+	// The resource might be deleted, or be moved to the trash.
+	p, err := f.locate(id)
+	if err != nil {
+		if err == ErrNotFound {
+			return nil
+		}
+		return err
+	}
+	return f.reencrypt(p)
+}
+
+// reencrypt re-encodes the file by the current options, if it is not encrypted by the current key.
+func (f *FsClient) reencrypt(p string) error {
+	b, err := afero.ReadFile(f.fs, p)
+	if err != nil {
+		return err
+	}
+	if keyID, ok := envelopeKeyID(b); ok && keyID == f.encryptor.keyID {
+		return nil
+	}
+	if b, err = f.decode(b); err != nil {
+		return err
+	}
+	if b, err = f.encode(b); err != nil {
+		return err
+	}
+	return f.writeAtomic(p, b)
+}
+
+// listIDs returns the ids of the resources under the configured layout, as well as the ones in the flat workdir if the
+// client owns them.
+func (f *FsClient) listIDs() ([]string, error) {
+	var ids []string
+	seen := map[string]bool{}
+	add := func(entries []os.FileInfo, legacy bool) {
+		for _, entry := range entries {
+			// Skip the directories and the hidden files, which are not resources.
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			// The resource files in the flat workdir have no extension.
+			id := entry.Name()
+			if !legacy {
+				if !strings.HasSuffix(id, f.extension) {
+					continue
+				}
+				id = strings.TrimSuffix(id, f.extension)
+			} else if f.extension != "" && strings.HasSuffix(id, f.extension) {
+				continue
+			}
+			if id == "" || seen[id] {
+				continue
+			}
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	readDir := func(dir string) ([]os.FileInfo, error) {
+		entries, err := afero.ReadDir(f.fs, dir)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return entries, err
 	}
-	return err
+
+	root := filepath.Join(f.dir, f.typ)
+	entries, err := readDir(root)
+	if err != nil {
+		return nil, err
+	}
+	add(entries, false)
+	if f.shardLength > 0 {
+		for _, entry := range entries {
+			if !entry.IsDir() || len(entry.Name()) != f.shardLength || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			shard, err := readDir(filepath.Join(root, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			add(shard, false)
+		}
+	}
+	if (root != f.dir || f.extension != "") && f.ownsFlat() {
+		entries, err := readDir(f.dir)
+		if err != nil {
+			return nil, err
+		}
+		add(entries, true)
+	}
+	return ids, nil
+}
+
+// writeFile atomically writes the resource file under the configured layout, the one in the flat workdir (if any and
+// owned by the client) is removed then.
+func (f *FsClient) writeFile(id string, b []byte) (err error) {
+	if b, err = f.encode(b); err != nil {
+		return err
+	}
+	target := f.path(id)
+	if err := f.writeAtomic(target, b); err != nil {
+		return err
+	}
+	if legacy := filepath.Join(f.dir, id); legacy != target && f.ownsFlat() {
+		if err := f.fs.Remove(legacy); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeAtomic atomically writes the file, by writing to a temporary file in the same directory, syncing it to the
+// disk, and then renaming it to the target. In case of a crash, the target is either the old one or the new one, but
+// never a partially written one.
+func (f *FsClient) writeAtomic(target string, b []byte) (err error) {
+	dir := filepath.Dir(target)
+	if err := f.fs.MkdirAll(dir, f.dirMode); err != nil {
+		return err
+	}
+	tmp, err := afero.TempFile(f.fs, dir, tmpFilePrefix+filepath.Base(target)+"-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			f.fs.Remove(tmp.Name())
+		}
+	}()
+	if _, err := tmp.Write(b); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := f.fs.Chmod(tmp.Name(), f.fileMode); err != nil {
+		return err
+	}
+	if err := f.fs.Rename(tmp.Name(), target); err != nil {
+		return err
+	}
+	// Sync the directory to persist the rename. This is best effort, as it is not supported on all platforms.
+	if d, err := f.fs.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// encode encodes the content to be stored in the resource file, by formatting, compressing and then encrypting it.
+func (f *FsClient) encode(b []byte) ([]byte, error) {
+	var err error
+	if f.pretty {
+		if b, err = canonicalJSON(b); err != nil {
+			return nil, err
+		}
+	}
+	if f.compress {
+		if b, err = compress(b); err != nil {
+			return nil, err
+		}
+	}
+	if f.encryptor != nil {
+		if b, err = f.encryptor.encrypt(b); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// decode decodes the content stored in the resource file, which is the reverse of encode. Whether the content is
+// encrypted or compressed is detected from the content itself, regardless of the current options.
+func (f *FsClient) decode(b []byte) ([]byte, error) {
+	b, err := f.encryptor.decrypt(b)
+	if err != nil {
+		return nil, err
+	}
+	return decompress(b)
+}
+
+// readLocked reads the resource with its lock held, given the store lock is already held.
+func (f *FsClient) readLocked(ctx context.Context, id string) ([]byte, string, error) {
+	unlock, err := f.acquire(ctx, resourceLockName(id), false)
+	if err != nil {
+		return nil, "", err
+	}
+	defer unlock()
+	return f.read(id)
+}
+
+// checkVersion returns ErrConflict if version is not empty and doesn't match the current version of the resource.
+// The lock of the resource is expected to be held.
+func (f *FsClient) checkVersion(id string, version string) error {
+	if version == "" {
+		return nil
+	}
+	_, current, err := f.read(id)
+	if err != nil {
+		return err
+	}
+	if current != version {
+		return ErrConflict
+	}
+	return nil
+}
+
+// canonicalJSON returns the pretty-printed JSON, with the object keys sorted and a trailing newline.
+func canonicalJSON(b []byte) ([]byte, error) {
+	var v interface{}
+	if err := unmarshalJSON(b, &v); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// contentVersion returns the version of the resource, which is the hash of its content.
+func contentVersion(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
 }
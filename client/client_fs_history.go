@@ -0,0 +1,275 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// historyDir is the directory under the resource directory holding the prior versions of the resources, in the
+// subdirectories named by the resource ids.
+const historyDir = ".history"
+
+// trashDir is the directory under the resource directory holding the prior versions of the deleted resources, in the
+// same way as historyDir.
+const trashDir = ".trash"
+
+var _ HistoryClient = &FsClient{}
+
+// revisionFile is a file holding a prior version of a resource, whose name is the timestamp in nanoseconds. The
+// content is kept as it was stored, i.e. it is still compressed or encrypted.
+type revisionFile struct {
+	path      string
+	timestamp time.Time
+}
+
+func (f *FsClient) revisionDir(base, id string) string {
+	return filepath.Join(f.dir, f.typ, base, id)
+}
+
+// revisions returns the revision files of the resource under the base directory, the oldest first.
+func (f *FsClient) revisions(base, id string) ([]revisionFile, error) {
+	dir := f.revisionDir(base, id)
+	entries, err := afero.ReadDir(f.fs, dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var revs []revisionFile
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		ns, err := strconv.ParseInt(entry.Name(), 10, 64)
+		if err != nil {
+			continue
+		}
+		revs = append(revs, revisionFile{path: filepath.Join(dir, entry.Name()), timestamp: time.Unix(0, ns)})
+	}
+	sort.Slice(revs, func(i, j int) bool { return revs[i].timestamp.Before(revs[j].timestamp) })
+	return revs, nil
+}
+
+// archive keeps the current version of the resource in the history, and prunes the history to the limit. It is a
+// no-op if the resource doesn't exist.
+func (f *FsClient) archive(id string, limit int) error {
+	if limit <= 0 {
+		return nil
+	}
+	p, err := f.locate(id)
+	if err != nil {
+		if err == ErrNotFound {
+			return nil
+		}
+		return err
+	}
+	b, err := afero.ReadFile(f.fs, p)
+	if err != nil {
+		return err
+	}
+	dir := f.revisionDir(historyDir, id)
+	ts := time.Now()
+	// Avoid overwriting the revision of the same timestamp, in case of the coarse clock.
+	for {
+		if _, err := f.fs.Stat(filepath.Join(dir, revisionName(ts))); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		ts = ts.Add(time.Nanosecond)
+	}
+	if err := f.writeAtomic(filepath.Join(dir, revisionName(ts)), b); err != nil {
+		return err
+	}
+	return f.prune(id, limit)
+}
+
+// prune removes the oldest revisions of the resource from the history, until at most limit of them are left.
+func (f *FsClient) prune(id string, limit int) error {
+	revs, err := f.revisions(historyDir, id)
+	if err != nil {
+		return err
+	}
+	for len(revs) > limit {
+		if err := f.fs.Remove(revs[0].path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		revs = revs[1:]
+	}
+	return nil
+}
+
+func revisionName(ts time.Time) string {
+	return fmt.Sprintf("%020d", ts.UnixNano())
+}
+
+// moveRevisions moves the revision files of the resource from one base directory to another.
+func (f *FsClient) moveRevisions(from, to, id string) error {
+	revs, err := f.revisions(from, id)
+	if err != nil {
+		return err
+	}
+	if len(revs) != 0 {
+		if err := f.fs.MkdirAll(f.revisionDir(to, id), f.dirMode); err != nil {
+			return err
+		}
+	}
+	for _, rev := range revs {
+		if err := f.fs.Rename(rev.path, filepath.Join(f.revisionDir(to, id), filepath.Base(rev.path))); err != nil {
+			return err
+		}
+	}
+	return f.fs.RemoveAll(f.revisionDir(from, id))
+}
+
+// remove removes the resource file. If the trash is enabled, the resource is moved to the trash together with its
+// history, otherwise its history is removed as well. The lock of the resource is expected to be held.
+func (f *FsClient) remove(id string) error {
+	p, err := f.locate(id)
+	if err != nil {
+		return err
+	}
+	if f.trashRetention > 0 {
+		// The deleted version is kept, besides the prior ones.
+		if err := f.archive(id, f.historyLimit+1); err != nil {
+			return err
+		}
+	}
+	if err := f.fs.Remove(p); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNotFound
+		}
+		return err
+	}
+	if f.trashRetention > 0 {
+		return f.moveRevisions(historyDir, trashDir, id)
+	}
+	return f.fs.RemoveAll(f.revisionDir(historyDir, id))
+}
+
+// purgeTrash removes the deleted resources which have been in the trash longer than the retention. The store lock is
+// expected to be held exclusively.
+func (f *FsClient) purgeTrash() error {
+	if f.trashRetention <= 0 {
+		return nil
+	}
+	entries, err := afero.ReadDir(f.fs, filepath.Join(f.dir, f.typ, trashDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		revs, err := f.revisions(trashDir, entry.Name())
+		if err != nil {
+			return err
+		}
+		// The newest revision is the deleted version, whose timestamp is when the resource is deleted.
+		if len(revs) != 0 && time.Since(revs[len(revs)-1].timestamp) < f.trashRetention {
+			continue
+		}
+		if err := f.fs.RemoveAll(f.revisionDir(trashDir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// History implements HistoryClient.
+func (f *FsClient) History(ctx context.Context, id string) ([]Revision, error) {
+	unlock, err := f.lock(ctx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return f.history(id)
+}
+
+// history returns the revisions of the resource, the newest first. ErrNotFound is returned if neither the resource
+// nor any revision of it exists.
+func (f *FsClient) history(id string) ([]Revision, error) {
+	var revisions []Revision
+	for _, base := range []string{historyDir, trashDir} {
+		revs, err := f.revisions(base, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, rev := range revs {
+			b, err := afero.ReadFile(f.fs, rev.path)
+			if err != nil {
+				return nil, err
+			}
+			if b, err = f.decode(b); err != nil {
+				return nil, fmt.Errorf("reading revision %s of resource %s: %w", filepath.Base(rev.path), id, err)
+			}
+			revisions = append(revisions, Revision{
+				Version:   contentVersion(b),
+				Timestamp: rev.timestamp,
+				Content:   b,
+				Deleted:   base == trashDir,
+			})
+		}
+	}
+	if len(revisions) == 0 {
+		if _, err := f.locate(id); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(revisions, func(i, j int) bool { return revisions[i].Timestamp.After(revisions[j].Timestamp) })
+	return revisions, nil
+}
+
+// Restore implements HistoryClient. The newest revision of the version is restored, and the current version of the
+// resource (if not deleted) is kept in the history.
+func (f *FsClient) Restore(ctx context.Context, id string, version string) error {
+	unlock, err := f.lock(ctx, id, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	revisions, err := f.history(id)
+	if err != nil {
+		return err
+	}
+	var rev *Revision
+	for i := range revisions {
+		if revisions[i].Version == version {
+			rev = &revisions[i]
+			break
+		}
+	}
+	if rev == nil {
+		return fmt.Errorf("revision %s of resource %s: %w", version, id, ErrNotFound)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if rev.Deleted {
+		// Bring the deleted resource back, together with its history, where the deleted version is one more than
+		// the limit.
+		if err := f.moveRevisions(trashDir, historyDir, id); err != nil {
+			return err
+		}
+		if err := f.prune(id, f.historyLimit); err != nil {
+			return err
+		}
+	} else if err := f.archive(id, f.historyLimit); err != nil {
+		return err
+	}
+	return f.writeFile(id, rev.Content)
+}
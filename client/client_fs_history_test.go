@@ -0,0 +1,127 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// revisionContents returns the contents of the revisions, the newest first.
+func revisionContents(revs []Revision) []string {
+	var contents []string
+	for _, rev := range revs {
+		contents = append(contents, string(rev.Content))
+	}
+	return contents
+}
+
+func TestFsClientHistory(t *testing.T) {
+	ctx := context.Background()
+	c, err := newFsClient(afero.NewMemMapFs(), "/tmp", &FsClientOption{HistoryLimit: 2})
+	require.NoError(t, err)
+	id, err := c.Create(ctx, []byte(`{"v": 1}`))
+	require.NoError(t, err, "create failed")
+	revs, err := c.History(ctx, id)
+	require.NoError(t, err, "history failed")
+	require.Empty(t, revs, "no history after creation")
+
+	require.NoError(t, c.Update(ctx, id, []byte(`{"v": 2}`), ""), "update failed")
+	require.NoError(t, c.Update(ctx, id, []byte(`{"v": 3}`), ""), "update failed")
+	require.NoError(t, c.Patch(ctx, id, []byte(`{"v": 4}`), ""), "patch failed")
+	revs, err = c.History(ctx, id)
+	require.NoError(t, err, "history failed")
+	require.Equal(t, []string{`{"v": 3}`, `{"v": 2}`}, revisionContents(revs), "only the last versions are kept")
+	require.True(t, revs[0].Timestamp.After(revs[1].Timestamp), "the newest first")
+	require.Equal(t, contentVersion([]byte(`{"v": 2}`)), revs[1].Version)
+	require.False(t, revs[0].Deleted)
+
+	require.NoError(t, c.Restore(ctx, id, revs[1].Version), "restore failed")
+	got, _, err := c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.Equal(t, `{"v": 2}`, string(got), "read after restore")
+	revs, err = c.History(ctx, id)
+	require.NoError(t, err, "history failed")
+	require.Equal(t, []string{`{"v":4}`, `{"v": 3}`}, revisionContents(revs), "the version before the restore is kept")
+
+	err = c.Restore(ctx, id, "not-exist")
+	require.True(t, errors.Is(err, ErrNotFound), "restore non existent version")
+	_, err = c.History(ctx, "not-exist")
+	require.Equal(t, ErrNotFound, err, "history of non existent resource")
+
+	// Without the trash, the history is deleted together with the resource.
+	require.NoError(t, c.Delete(ctx, id, ""), "delete failed")
+	_, err = c.History(ctx, id)
+	require.Equal(t, ErrNotFound, err, "history of deleted resource")
+}
+
+func TestFsClientTrash(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	c, err := newFsClient(fs, "/tmp", &FsClientOption{HistoryLimit: 1, TrashRetention: time.Hour})
+	require.NoError(t, err)
+	id, err := c.Create(ctx, []byte(`{"v": 1}`))
+	require.NoError(t, err, "create failed")
+	require.NoError(t, c.Update(ctx, id, []byte(`{"v": 2}`), ""), "update failed")
+	require.NoError(t, c.Delete(ctx, id, ""), "delete failed")
+
+	_, _, err = c.Read(ctx, id)
+	require.Equal(t, ErrNotFound, err, "read deleted resource")
+	objs, err := c.List(ctx, false)
+	require.NoError(t, err, "list failed")
+	require.Empty(t, objs, "the deleted resource is not listed")
+	revs, err := c.History(ctx, id)
+	require.NoError(t, err, "history of deleted resource")
+	require.Equal(t, []string{`{"v": 2}`, `{"v": 1}`}, revisionContents(revs), "the deleted version and its history are in the trash")
+	require.True(t, revs[0].Deleted && revs[1].Deleted)
+
+	require.NoError(t, c.Restore(ctx, id, revs[0].Version), "restore deleted resource")
+	got, _, err := c.Read(ctx, id)
+	require.NoError(t, err, "read restored resource")
+	require.Equal(t, `{"v": 2}`, string(got))
+	revs, err = c.History(ctx, id)
+	require.NoError(t, err, "history failed")
+	require.Equal(t, []string{`{"v": 2}`}, revisionContents(revs), "the history brought back is pruned to the limit")
+	require.False(t, revs[0].Deleted, "the history is brought back from the trash")
+
+	// The trash is kept within the retention, and purged after it.
+	require.NoError(t, c.Delete(ctx, id, ""), "delete failed")
+	_, err = newFsClient(fs, "/tmp", &FsClientOption{TrashRetention: time.Hour})
+	require.NoError(t, err)
+	_, err = c.History(ctx, id)
+	require.NoError(t, err, "the trash within the retention is kept")
+	_, err = newFsClient(fs, "/tmp", &FsClientOption{TrashRetention: time.Nanosecond})
+	require.NoError(t, err)
+	_, err = c.History(ctx, id)
+	require.Equal(t, ErrNotFound, err, "the trash after the retention is purged")
+}
+
+func TestFsClientHistoryRotateKey(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	k1 := &EncryptionOption{KeyID: "k1", Key: testEncryptionKey(1)}
+	c, err := newFsClient(fs, "/tmp", &FsClientOption{HistoryLimit: 1, TrashRetention: time.Hour, Encryption: k1})
+	require.NoError(t, err)
+	id1, err := c.Create(ctx, []byte(`{"v": 1}`))
+	require.NoError(t, err, "create failed")
+	require.NoError(t, c.Update(ctx, id1, []byte(`{"v": 2}`), ""), "update failed")
+	id2, err := c.Create(ctx, []byte(`{"v": 1}`))
+	require.NoError(t, err, "create failed")
+	require.NoError(t, c.Delete(ctx, id2, ""), "delete failed")
+
+	k2 := &EncryptionOption{KeyID: "k2", Key: testEncryptionKey(2), PreviousKeys: map[string][]byte{"k1": k1.Key}}
+	c, err = newFsClient(fs, "/tmp", &FsClientOption{HistoryLimit: 1, TrashRetention: time.Hour, Encryption: k2})
+	require.NoError(t, err)
+	require.NoError(t, c.RotateKey(ctx), "rotate failed")
+
+	c, err = newFsClient(fs, "/tmp", &FsClientOption{Encryption: &EncryptionOption{KeyID: "k2", Key: k2.Key}})
+	require.NoError(t, err)
+	for _, id := range []string{id1, id2} {
+		revs, err := c.History(ctx, id)
+		require.NoError(t, err, "history is readable by the new key only")
+		require.Len(t, revs, 1)
+	}
+}
@@ -1,24 +1,566 @@
 package client
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/require"
 )
 
 func TestFsClient(t *testing.T) {
-	c := &FsClient{fs: afero.NewMemMapFs(), dir: "/tmp"}
+	ctx := context.Background()
+	c, err := newFsClient(afero.NewMemMapFs(), "/tmp", nil)
+	require.NoError(t, err)
 	content := []byte(`{"name": "foo"}`)
-	id, err := c.Create(content)
+	id, err := c.Create(ctx, content)
 	require.NoError(t, err, "create failed")
-	got, err := c.Read(id)
+	got, _, err := c.Read(ctx, id)
 	require.Equal(t, content, got, "read after creation")
 	content = []byte(`{"name": "bar"}`)
-	require.NoError(t, c.Update(id, content), "update failed")
-	got, err = c.Read(id)
+	require.NoError(t, c.Update(ctx, id, content, ""), "update failed")
+	got, _, err = c.Read(ctx, id)
 	require.Equal(t, content, got, "read after update")
-	require.NoError(t, c.Delete(id), "delete failed")
-	_, err = c.Read(id)
+	require.NoError(t, c.Delete(ctx, id, ""), "delete failed")
+	_, _, err = c.Read(ctx, id)
 	require.Equal(t, ErrNotFound, err, "read non existent resource should return ErrNotFound")
 }
+
+func TestFsClientVersion(t *testing.T) {
+	ctx := context.Background()
+	c, err := newFsClient(afero.NewMemMapFs(), "/tmp", nil)
+	require.NoError(t, err)
+	id, err := c.Create(ctx, []byte(`{"name": "foo"}`))
+	require.NoError(t, err, "create failed")
+	_, version, err := c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+
+	// Someone else updates the resource.
+	require.NoError(t, c.Update(ctx, id, []byte(`{"name": "bar"}`), ""), "blind update failed")
+	require.Equal(t, ErrConflict, c.Update(ctx, id, []byte(`{"name": "baz"}`), version), "update with stale version")
+	require.Equal(t, ErrConflict, c.Delete(ctx, id, version), "delete with stale version")
+
+	_, version, err = c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.NoError(t, c.Update(ctx, id, []byte(`{"name": "baz"}`), version), "update with current version")
+	_, version, err = c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.NoError(t, c.Delete(ctx, id, version), "delete with current version")
+}
+
+func TestFsClientPatch(t *testing.T) {
+	ctx := context.Background()
+	c, err := newFsClient(afero.NewMemMapFs(), "/tmp", nil)
+	require.NoError(t, err)
+	id, err := c.Create(ctx, []byte(`{"name": "foo", "age": 1, "tags": {"a": "b"}}`))
+	require.NoError(t, err, "create failed")
+	_, version, err := c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+
+	require.NoError(t, c.Patch(ctx, id, []byte(`{"name": "bar", "age": null, "tags": {"c": "d"}}`), version), "patch failed")
+	got, _, err := c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.JSONEq(t, `{"name": "bar", "tags": {"a": "b", "c": "d"}}`, string(got), "read after patch")
+
+	require.Equal(t, ErrConflict, c.Patch(ctx, id, []byte(`{"name": "baz"}`), version), "patch with stale version")
+	require.Equal(t, ErrNotFound, c.Patch(ctx, "not-exist", []byte(`{"name": "baz"}`), ""), "patch non existent resource")
+}
+
+func TestFsClientList(t *testing.T) {
+	ctx := context.Background()
+	c, err := newFsClient(afero.NewMemMapFs(), "/tmp", nil)
+	require.NoError(t, err)
+	require.NoError(t, c.fs.MkdirAll(c.dir, 0755))
+
+	objs, err := c.List(ctx, false)
+	require.NoError(t, err, "list empty directory")
+	require.Empty(t, objs, "list empty directory")
+
+	id1, err := c.Create(ctx, []byte(`{"name": "foo"}`))
+	require.NoError(t, err, "create failed")
+	id2, err := c.Create(ctx, []byte(`{"name": "bar"}`))
+	require.NoError(t, err, "create failed")
+	// Hidden files and directories are not resources.
+	require.NoError(t, afero.WriteFile(c.fs, "/tmp/.hidden", nil, 0644))
+	require.NoError(t, c.fs.Mkdir("/tmp/subdir", 0755))
+
+	objs, err = c.List(ctx, false)
+	require.NoError(t, err, "list failed")
+	require.ElementsMatch(t, []Object{{ID: id1}, {ID: id2}}, objs, "list without content")
+
+	objs, err = c.List(ctx, true)
+	require.NoError(t, err, "list failed")
+	require.ElementsMatch(t, []Object{{ID: id1, Content: []byte(`{"name": "foo"}`), Version: contentVersion([]byte(`{"name": "foo"}`))}, {ID: id2, Content: []byte(`{"name": "bar"}`), Version: contentVersion([]byte(`{"name": "bar"}`))}}, objs, "list with content")
+}
+
+// renameFailFs fails the renames, to simulate a crash in the middle of a write.
+type renameFailFs struct {
+	afero.Fs
+}
+
+func (renameFailFs) Rename(oldname, newname string) error {
+	return errors.New("rename failed")
+}
+
+func TestFsClientAtomicWrite(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	c, err := newFsClient(fs, "/tmp", nil)
+	require.NoError(t, err)
+	id, err := c.Create(ctx, []byte(`{"name": "foo"}`))
+	require.NoError(t, err, "create failed")
+
+	c.fs = renameFailFs{Fs: fs}
+	_, err = c.Create(ctx, []byte(`{"name": "bar"}`))
+	require.Error(t, err, "create with failed rename")
+	require.Error(t, c.Update(ctx, id, []byte(`{"name": "bar"}`), ""), "update with failed rename")
+
+	objs, err := c.List(ctx, true)
+	require.NoError(t, err, "list failed")
+	require.Equal(t, []Object{{ID: id, Content: []byte(`{"name": "foo"}`), Version: contentVersion([]byte(`{"name": "foo"}`))}}, objs, "no half-written resource is exposed")
+	entries, err := afero.ReadDir(fs, "/tmp")
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "the temporary files are cleaned up")
+}
+
+func TestFsClientRecover(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, fs.MkdirAll("/tmp", 0755))
+	require.NoError(t, afero.WriteFile(fs, "/tmp/"+tmpFilePrefix+"foo-123", []byte(`{"name"`), 0600))
+	require.NoError(t, afero.WriteFile(fs, "/tmp/foo", []byte(`{"name": "foo"}`), 0644))
+
+	_, err := newFsClient(fs, "/tmp", nil)
+	require.NoError(t, err)
+	entries, err := afero.ReadDir(fs, "/tmp")
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "the left over temporary files are cleaned up")
+	require.Equal(t, "foo", entries[0].Name())
+}
+
+// increment increases the counter stored in the resource by one, by the read-modify-write with the version check.
+func increment(ctx context.Context, c Client, id string) error {
+	for {
+		b, version, err := c.Read(ctx, id)
+		if err != nil {
+			return err
+		}
+		var counter struct {
+			Count int `json:"count"`
+		}
+		if err := json.Unmarshal(b, &counter); err != nil {
+			return err
+		}
+		counter.Count++
+		if b, err = json.Marshal(counter); err != nil {
+			return err
+		}
+		err = c.Update(ctx, id, b, version)
+		if err == ErrConflict {
+			continue
+		}
+		return err
+	}
+}
+
+func hammer(ctx context.Context, c Client, id string, n, m int) error {
+	errCh := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			for j := 0; j < m; j++ {
+				if err := increment(ctx, c, id); err != nil {
+					errCh <- err
+					return
+				}
+			}
+			errCh <- nil
+		}()
+	}
+	for i := 0; i < n; i++ {
+		if err := <-errCh; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestFsClientConcurrent(t *testing.T) {
+	ctx := context.Background()
+	for name, newFs := range map[string]func() (afero.Fs, string){
+		"MemMapFs": func() (afero.Fs, string) { return afero.NewMemMapFs(), "/tmp" },
+		"OsFs":     func() (afero.Fs, string) { return afero.NewOsFs(), t.TempDir() },
+	} {
+		t.Run(name, func(t *testing.T) {
+			fs, dir := newFs()
+			c, err := newFsClient(fs, dir, nil)
+			require.NoError(t, err)
+			id, err := c.Create(ctx, []byte(`{"count": 0}`))
+			require.NoError(t, err, "create failed")
+
+			// List concurrently, which shall not see any half-written resource.
+			stop := make(chan struct{})
+			listErr := make(chan error, 1)
+			go func() {
+				for {
+					select {
+					case <-stop:
+						listErr <- nil
+						return
+					default:
+					}
+					if _, err := c.List(ctx, true); err != nil {
+						listErr <- err
+						return
+					}
+				}
+			}()
+			require.NoError(t, hammer(ctx, c, id, 20, 20))
+			close(stop)
+			require.NoError(t, <-listErr, "list failed")
+
+			b, _, err := c.Read(ctx, id)
+			require.NoError(t, err, "read failed")
+			require.JSONEq(t, `{"count": 400}`, string(b), "no update is lost")
+		})
+	}
+}
+
+const (
+	envHammerDir = "DEMO_TEST_HAMMER_DIR"
+	envHammerID  = "DEMO_TEST_HAMMER_ID"
+)
+
+// TestFsClientHammerHelper is not a real test, but the subprocess spawned by TestFsClientMultiProcess.
+func TestFsClientHammerHelper(t *testing.T) {
+	dir := os.Getenv(envHammerDir)
+	if dir == "" {
+		t.Skip("only run as a subprocess")
+	}
+	c, err := NewFsClient(dir, &FsClientOption{LockTimeout: time.Minute})
+	require.NoError(t, err)
+	require.NoError(t, hammer(context.Background(), c, os.Getenv(envHammerID), 5, 10))
+}
+
+func TestFsClientMultiProcess(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+	c, err := NewFsClient(dir, nil)
+	require.NoError(t, err)
+	id, err := c.Create(ctx, []byte(`{"count": 0}`))
+	require.NoError(t, err, "create failed")
+
+	var cmds []*exec.Cmd
+	var outputs []*bytes.Buffer
+	for i := 0; i < 4; i++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestFsClientHammerHelper$")
+		cmd.Env = append(os.Environ(), envHammerDir+"="+dir, envHammerID+"="+id)
+		output := &bytes.Buffer{}
+		cmd.Stdout, cmd.Stderr = output, output
+		require.NoError(t, cmd.Start())
+		cmds = append(cmds, cmd)
+		outputs = append(outputs, output)
+	}
+	// Hammer from this process as well.
+	require.NoError(t, hammer(ctx, c, id, 5, 10))
+	for i, cmd := range cmds {
+		require.NoError(t, cmd.Wait(), "subprocess failed: %s", outputs[i])
+	}
+
+	b, _, err := c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.JSONEq(t, `{"count": 250}`, string(b), "no update is lost")
+}
+
+func TestFsClientLockTimeout(t *testing.T) {
+	ctx := context.Background()
+	c, err := newFsClient(afero.NewMemMapFs(), "/tmp", &FsClientOption{LockTimeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+	id, err := c.Create(ctx, []byte(`{"name": "foo"}`))
+	require.NoError(t, err, "create failed")
+
+	unlock, err := c.lock(ctx, id, true)
+	require.NoError(t, err)
+	_, _, err = c.Read(ctx, id)
+	require.ErrorContains(t, err, "timeout acquiring the lock", "read while the resource is locked")
+	unlock()
+	_, _, err = c.Read(ctx, id)
+	require.NoError(t, err, "read after the resource is unlocked")
+}
+
+func TestFsClientLockFiles(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	c, err := NewFsClient(dir, nil)
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		id, err := c.Create(ctx, []byte(`{"name": "foo"}`))
+		require.NoError(t, err, "create failed")
+		require.NoError(t, c.Delete(ctx, id, ""), "delete failed")
+	}
+	_, _, err = c.Read(ctx, "not-exist")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// The resources are locked by the shards, instead of leaving a lock file for each id ever used.
+	entries, err := os.ReadDir(filepath.Join(dir, lockDir))
+	require.NoError(t, err)
+	require.LessOrEqual(t, len(entries), 12)
+	for _, entry := range entries {
+		require.Regexp(t, `^(\.store|shard-[0-9a-f]{2})\.lock$`, entry.Name())
+	}
+}
+
+func TestFsClientCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c, err := newFsClient(afero.NewMemMapFs(), "/tmp", nil)
+	require.NoError(t, err)
+	_, err = c.Create(ctx, []byte(`{"name": "foo"}`))
+	require.ErrorIs(t, err, context.Canceled, "create with canceled context")
+}
+
+func TestFsClientLayout(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	// A resource written without any layout option.
+	require.NoError(t, fs.MkdirAll("/tmp", 0755))
+	require.NoError(t, afero.WriteFile(fs, "/tmp/legacy", []byte(`{"name": "legacy"}`), 0644))
+
+	root, err := newFsClient(fs, "/tmp", &FsClientOption{
+		Extension:        ".json",
+		ShardLength:      2,
+		LegacyCollection: "foo",
+		Pretty:           true,
+		FileMode:         0600,
+		DirMode:          0700,
+	})
+	require.NoError(t, err)
+	c, err := root.Collection("foo")
+	require.NoError(t, err)
+	id, err := c.Create(ctx, []byte(`{"name": "foo", "age": 1, "tags": {"b": "<b>", "a": 1.50}}`))
+	require.NoError(t, err, "create failed")
+
+	p := filepath.Join("/tmp", "foo", id[:2], id+".json")
+	info, err := fs.Stat(p)
+	require.NoError(t, err, "the resource file is stored under the layout")
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm(), "file mode")
+	info, err = fs.Stat(filepath.Dir(p))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0700), info.Mode().Perm(), "dir mode")
+	b, err := afero.ReadFile(fs, p)
+	require.NoError(t, err)
+	require.Equal(t, `{
+  "age": 1,
+  "name": "foo",
+  "tags": {
+    "a": 1.50,
+    "b": "<b>"
+  }
+}
+`, string(b), "canonical pretty-printed JSON")
+
+	got, _, err := c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.Equal(t, b, got, "read the resource under the layout")
+
+	objs, err := c.List(ctx, false)
+	require.NoError(t, err, "list failed")
+	require.ElementsMatch(t, []Object{{ID: id}, {ID: "legacy"}}, objs, "list both the resources under the layout and in the flat workdir")
+
+	// The resource in the flat workdir is moved to the layout once updated.
+	got, version, err := c.Read(ctx, "legacy")
+	require.NoError(t, err, "read the resource in the flat workdir")
+	require.JSONEq(t, `{"name": "legacy"}`, string(got))
+	require.NoError(t, c.Patch(ctx, "legacy", []byte(`{"age": 2}`), version), "patch the resource in the flat workdir")
+	_, err = fs.Stat("/tmp/legacy")
+	require.True(t, errors.Is(err, os.ErrNotExist), "the resource file in the flat workdir is removed")
+	got, _, err = c.Read(ctx, "legacy")
+	require.NoError(t, err, "read failed")
+	require.JSONEq(t, `{"name": "legacy", "age": 2}`, string(got))
+
+	require.NoError(t, c.Delete(ctx, id, ""), "delete failed")
+	_, _, err = c.Read(ctx, id)
+	require.Equal(t, ErrNotFound, err, "read non existent resource should return ErrNotFound")
+	objs, err = c.List(ctx, false)
+	require.NoError(t, err, "list failed")
+	require.Equal(t, []Object{{ID: "legacy"}}, objs)
+
+	_, err = newFsClient(fs, "/tmp", &FsClientOption{LegacyCollection: "../foo"})
+	require.Error(t, err, "invalid legacy collection")
+}
+
+func TestFsClientLayoutRecover(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, fs.MkdirAll("/tmp/foo/ab", 0755))
+	require.NoError(t, afero.WriteFile(fs, "/tmp/foo/ab/"+tmpFilePrefix+"abc-123", []byte(`{"name"`), 0600))
+	require.NoError(t, afero.WriteFile(fs, "/tmp/foo/ab/abc.json", []byte(`{"name": "foo"}`), 0644))
+
+	root, err := newFsClient(fs, "/tmp", &FsClientOption{Extension: ".json", ShardLength: 2})
+	require.NoError(t, err)
+	c, err := root.Collection("foo")
+	require.NoError(t, err)
+	entries, err := afero.ReadDir(fs, "/tmp/foo/ab")
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "the left over temporary files in the subdirectories are cleaned up")
+	objs, err := c.List(context.Background(), true)
+	require.NoError(t, err)
+	require.Equal(t, []Object{{ID: "abc", Content: []byte(`{"name": "foo"}`), Version: contentVersion([]byte(`{"name": "foo"}`))}}, objs)
+}
+
+func TestFsClientEncryption(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	require.NoError(t, fs.MkdirAll("/tmp", 0755))
+	// A resource written before the encryption is enabled.
+	require.NoError(t, afero.WriteFile(fs, "/tmp/plain", []byte(`{"password": "plain"}`), 0644))
+
+	k1 := &EncryptionOption{KeyID: "k1", Key: testEncryptionKey(1)}
+	c, err := newFsClient(fs, "/tmp", &FsClientOption{Encryption: k1})
+	require.NoError(t, err)
+	content := []byte(`{"password": "secret"}`)
+	id, err := c.Create(ctx, content)
+	require.NoError(t, err, "create failed")
+	raw, err := afero.ReadFile(fs, "/tmp/"+id)
+	require.NoError(t, err)
+	require.NotContains(t, string(raw), "secret", "the resource file is encrypted")
+
+	got, version, err := c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.Equal(t, content, got, "read the decrypted content")
+	require.Equal(t, contentVersion(content), version, "the version is the hash of the plaintext")
+	got, _, err = c.Read(ctx, "plain")
+	require.NoError(t, err, "read the resource not encrypted")
+	require.Equal(t, []byte(`{"password": "plain"}`), got)
+
+	require.NoError(t, c.Patch(ctx, id, []byte(`{"user": "foo"}`), version), "patch failed")
+	got, _, err = c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.JSONEq(t, `{"password": "secret", "user": "foo"}`, string(got), "read after patch")
+
+	// Without the key, the encrypted resource can't be read.
+	plainClient, err := newFsClient(fs, "/tmp", nil)
+	require.NoError(t, err)
+	_, _, err = plainClient.Read(ctx, id)
+	require.ErrorContains(t, err, "no encryption key is configured")
+	require.Error(t, plainClient.RotateKey(ctx), "rotate without the key")
+
+	// Rotate to a new key.
+	k2 := &EncryptionOption{KeyID: "k2", Key: testEncryptionKey(2), PreviousKeys: map[string][]byte{"k1": k1.Key}}
+	c2, err := newFsClient(fs, "/tmp", &FsClientOption{Encryption: k2})
+	require.NoError(t, err)
+	_, version, err = c2.Read(ctx, id)
+	require.NoError(t, err, "read by the previous key")
+	require.NoError(t, c2.RotateKey(ctx), "rotate failed")
+	for _, id := range []string{id, "plain"} {
+		raw, err := afero.ReadFile(fs, "/tmp/"+id)
+		require.NoError(t, err)
+		keyID, ok := envelopeKeyID(raw)
+		require.True(t, ok, "%s is encrypted", id)
+		require.Equal(t, "k2", keyID, "%s is encrypted by the new key", id)
+	}
+	_, newVersion, err := c2.Read(ctx, id)
+	require.NoError(t, err, "read after rotation")
+	require.Equal(t, version, newVersion, "the version doesn't change by the rotation")
+	_, _, err = c.Read(ctx, id)
+	require.ErrorContains(t, err, `unknown key "k2"`, "read by the old key after rotation")
+	objs, err := c2.List(ctx, true)
+	require.NoError(t, err, "list failed")
+	require.ElementsMatch(t, []Object{
+		{ID: id, Content: []byte(`{"password":"secret","user":"foo"}`), Version: contentVersion([]byte(`{"password":"secret","user":"foo"}`))},
+		{ID: "plain", Content: []byte(`{"password": "plain"}`), Version: contentVersion([]byte(`{"password": "plain"}`))},
+	}, objs, "list the decrypted content")
+}
+
+func TestFsClientCompress(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	require.NoError(t, fs.MkdirAll("/tmp", 0755))
+	// A resource written before the compression is enabled.
+	require.NoError(t, afero.WriteFile(fs, "/tmp/plain", []byte(`{"name": "plain"}`), 0644))
+
+	c, err := newFsClient(fs, "/tmp", &FsClientOption{Compress: true})
+	require.NoError(t, err)
+	content := []byte(`{"name": "foo"}`)
+	id, err := c.Create(ctx, content)
+	require.NoError(t, err, "create failed")
+	raw, err := afero.ReadFile(fs, "/tmp/"+id)
+	require.NoError(t, err)
+	require.True(t, isCompressed(raw), "the resource file is compressed")
+
+	got, version, err := c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.Equal(t, content, got, "read the decompressed content")
+	require.Equal(t, contentVersion(content), version, "the version is the hash of the decompressed content")
+	got, _, err = c.Read(ctx, "plain")
+	require.NoError(t, err, "read the resource not compressed")
+	require.Equal(t, []byte(`{"name": "plain"}`), got)
+	require.NoError(t, c.Patch(ctx, id, []byte(`{"age": 1}`), version), "patch failed")
+
+	// Compressed and then encrypted, and read by the client without compression.
+	encryption := &EncryptionOption{KeyID: "k1", Key: testEncryptionKey(1)}
+	c, err = newFsClient(fs, "/tmp", &FsClientOption{Compress: true, Pretty: true, Encryption: encryption})
+	require.NoError(t, err)
+	require.NoError(t, c.Update(ctx, "plain", []byte(`{"name": "plain", "age": 2}`), ""), "update failed")
+	c, err = newFsClient(fs, "/tmp", &FsClientOption{Encryption: encryption})
+	require.NoError(t, err)
+	objs, err := c.List(ctx, true)
+	require.NoError(t, err, "list failed")
+	pretty := "{\n  \"age\": 2,\n  \"name\": \"plain\"\n}\n"
+	require.ElementsMatch(t, []Object{
+		{ID: id, Content: []byte(`{"age":1,"name":"foo"}`), Version: contentVersion([]byte(`{"age":1,"name":"foo"}`))},
+		{ID: "plain", Content: []byte(pretty), Version: contentVersion([]byte(pretty))},
+	}, objs, "list the decoded content")
+}
+
+func TestFsClientCollection(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	// A resource written before the collections are used.
+	require.NoError(t, fs.MkdirAll("/tmp", 0755))
+	require.NoError(t, afero.WriteFile(fs, "/tmp/legacy", []byte(`{"name": "legacy"}`), 0644))
+	c, err := newFsClient(fs, "/tmp", &FsClientOption{Extension: ".json", LegacyCollection: "foos"})
+	require.NoError(t, err)
+	foos, err := c.Collection("foos")
+	require.NoError(t, err)
+	bars, err := c.Collection("bars")
+	require.NoError(t, err)
+
+	fooID, err := foos.Create(ctx, []byte(`{"name": "foo"}`))
+	require.NoError(t, err)
+	barID, err := bars.Create(ctx, []byte(`{"name": "bar"}`))
+	require.NoError(t, err)
+	exists, err := afero.Exists(fs, "/tmp/foos/"+fooID+".json")
+	require.NoError(t, err)
+	require.True(t, exists, "the resource file is in the subdirectory of the collection")
+
+	// The resource in the flat workdir only belongs to the legacy collection.
+	objs, err := foos.List(ctx, false)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []Object{{ID: fooID}, {ID: "legacy"}}, objs)
+	objs, err = bars.List(ctx, false)
+	require.NoError(t, err)
+	require.Equal(t, []Object{{ID: barID}}, objs)
+	_, _, err = bars.Read(ctx, fooID)
+	require.ErrorIs(t, err, ErrNotFound)
+	_, _, err = bars.Read(ctx, "legacy")
+	require.ErrorIs(t, err, ErrNotFound)
+	require.ErrorIs(t, bars.Delete(ctx, "legacy", ""), ErrNotFound)
+	_, _, err = foos.Read(ctx, "legacy")
+	require.NoError(t, err)
+	objs, err = c.List(ctx, false)
+	require.NoError(t, err)
+	require.Equal(t, []Object{{ID: "legacy"}}, objs, "the collections are not seen by the workdir")
+
+	for _, name := range []string{"", ".history", "a/b"} {
+		_, err := c.Collection(name)
+		require.Error(t, err, name)
+	}
+}
@@ -0,0 +1,268 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// gitDir is the directory under the working tree holding the git repository.
+const gitDir = ".git"
+
+// gitLockName is the name of the lock serializing the commits to the repository.
+const gitLockName = ".git"
+
+// GitClient stores the resources as the files in a local git working tree, in the same way as the FsClient, and
+// commits each change of a resource to the repository, by the git command.
+type GitClient struct {
+	fs      *FsClient
+	command string
+	env     []string
+}
+
+type GitClientOption struct {
+	// LockTimeout is the maximum time to wait for acquiring a lock. Zero means waiting until the context is done.
+	LockTimeout time.Duration
+	// Extension is the file extension of the resource files, e.g. ".json". Defaults to no extension.
+	Extension string
+	// Pretty makes the resource files written as the canonical pretty-printed JSON, with the keys sorted, which makes
+	// the diffs between the commits readable.
+	Pretty bool
+
+	// AuthorName is the name of the author and committer of the commits. Defaults to "terraform-provider-demo".
+	AuthorName string
+	// AuthorEmail is the email of the author and committer of the commits. Defaults to "terraform-provider-demo@localhost".
+	AuthorEmail string
+	// Branch is the branch to commit to, which is checked out, or created from the current HEAD if not exists.
+	// Defaults to the current branch.
+	Branch string
+	// Command is the path to the git command. Defaults to "git" found in the PATH.
+	Command string
+	// WireLog configures the redaction of the file contents logged to the wire log subsystem. Nil means the default
+	// redaction.
+	WireLog *WireLogOption
+}
+
+// NewGitClient returns the client storing the resources in the git working tree of dir. The repository is initialized
+// if not exists.
+func NewGitClient(dir string, opt *GitClientOption) (Client, error) {
+	if opt == nil {
+		opt = &GitClientOption{}
+	}
+	g := &GitClient{
+		command: opt.Command,
+		env:     os.Environ(),
+	}
+	if g.command == "" {
+		g.command = "git"
+	}
+	name, email := opt.AuthorName, opt.AuthorEmail
+	if name == "" {
+		name = "terraform-provider-demo"
+	}
+	if email == "" {
+		email = "terraform-provider-demo@localhost"
+	}
+	g.env = append(g.env,
+		"GIT_AUTHOR_NAME="+name,
+		"GIT_AUTHOR_EMAIL="+email,
+		"GIT_COMMITTER_NAME="+name,
+		"GIT_COMMITTER_EMAIL="+email,
+		"GIT_TERMINAL_PROMPT=0",
+	)
+
+	fs, err := newFsClient(afero.NewOsFs(), dir, &FsClientOption{
+		LockTimeout: opt.LockTimeout,
+		Extension:   opt.Extension,
+		Pretty:      opt.Pretty,
+		WireLog:     opt.WireLog,
+	})
+	if err != nil {
+		return nil, err
+	}
+	g.fs = fs
+	if err := g.init(context.Background(), opt.Branch); err != nil {
+		return nil, fmt.Errorf("initializing the git repository %s: %v", dir, err)
+	}
+	return g, nil
+}
+
+// init initializes the repository if not exists, excludes the lock files from it, and checks out the branch.
+func (g *GitClient) init(ctx context.Context, branch string) error {
+	unlock, err := acquireLock(ctx, g.fs.locker, gitLockName, true, g.fs.lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := os.Stat(filepath.Join(g.fs.dir, gitDir)); errors.Is(err, os.ErrNotExist) {
+		if _, err := g.git(ctx, "init", "--quiet"); err != nil {
+			return err
+		}
+	}
+
+	exclude := filepath.Join(g.fs.dir, gitDir, "info", "exclude")
+	b, err := os.ReadFile(exclude)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	pattern := "/" + lockDir + "/"
+	if !strings.Contains(string(b), pattern) {
+		if len(b) != 0 && !bytes.HasSuffix(b, []byte("\n")) {
+			b = append(b, '\n')
+		}
+		b = append(b, pattern+"\n"...)
+		if err := os.MkdirAll(filepath.Dir(exclude), g.fs.dirMode); err != nil {
+			return err
+		}
+		if err := os.WriteFile(exclude, b, g.fs.fileMode); err != nil {
+			return err
+		}
+	}
+
+	if branch == "" {
+		return nil
+	}
+	current, err := g.git(ctx, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err == nil && strings.TrimSpace(current) == branch {
+		return nil
+	}
+	if _, err := g.git(ctx, "rev-parse", "--quiet", "--verify", "HEAD"); err != nil {
+		// The repository has no commit yet, the branch is created by the first commit.
+		_, err := g.git(ctx, "symbolic-ref", "HEAD", "refs/heads/"+branch)
+		return err
+	}
+	if _, err := g.git(ctx, "rev-parse", "--quiet", "--verify", "refs/heads/"+branch); err != nil {
+		_, err := g.git(ctx, "checkout", "--quiet", "-b", branch)
+		return err
+	}
+	_, err = g.git(ctx, "checkout", "--quiet", branch)
+	return err
+}
+
+// git runs the git command in the working tree, returns its stdout.
+func (g *GitClient) git(ctx context.Context, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, g.command, args...)
+	cmd.Dir = g.fs.dir
+	cmd.Env = g.env
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("running git %s: %v. Stderr: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// commit commits the change of the resource file, if any. The message references the operation and the id.
+func (g *GitClient) commit(ctx context.Context, id string, operation string) error {
+	rel, err := filepath.Rel(g.fs.dir, g.fs.path(id))
+	if err != nil {
+		return err
+	}
+	if _, err := g.git(ctx, "add", "--all", "--", rel); err != nil {
+		return err
+	}
+	status, err := g.git(ctx, "status", "--porcelain", "--", rel)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(status) == "" {
+		return nil
+	}
+	_, err = g.git(ctx, "commit", "--quiet", "--message", fmt.Sprintf("%s %s", operation, id), "--", rel)
+	return err
+}
+
+// change runs the change of the resource and commits it, while holding the git lock. If the commit fails, the change
+// is reverted by undo, so that the working tree keeps matching the last commit.
+func (g *GitClient) change(ctx context.Context, operation string, f func() (string, error), undo func(id string) error) (string, error) {
+	unlock, err := acquireLock(ctx, g.fs.locker, gitLockName, true, g.fs.lockTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	id, err := f()
+	if err != nil {
+		return "", err
+	}
+	if err := g.commit(ctx, id, operation); err != nil {
+		err = fmt.Errorf("committing the %s of %s: %w", strings.ToLower(operation), id, err)
+		if undo != nil {
+			if uerr := undo(id); uerr != nil {
+				return "", fmt.Errorf("%w. Reverting the %s: %v", err, strings.ToLower(operation), uerr)
+			}
+		}
+		return "", err
+	}
+	return id, nil
+}
+
+func (g *GitClient) Create(ctx context.Context, b []byte) (string, error) {
+	return g.change(ctx, "Create", func() (string, error) {
+		return g.fs.Create(ctx, b)
+	}, g.uncreate)
+}
+
+// uncreate removes the resource file created, and its staged change from the index. The context of the creation is
+// not used, as it might have been done, which fails the commit.
+func (g *GitClient) uncreate(id string) error {
+	ctx := context.Background()
+	if err := g.fs.Delete(ctx, id, ""); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	rel, err := filepath.Rel(g.fs.dir, g.fs.path(id))
+	if err != nil {
+		return err
+	}
+	_, err = g.git(ctx, "rm", "--cached", "--quiet", "--ignore-unmatch", "--", rel)
+	return err
+}
+
+func (g *GitClient) Read(ctx context.Context, id string) ([]byte, string, error) {
+	return g.fs.Read(ctx, id)
+}
+
+// revert reverts the resource file, and its staged change, to the last commit. The context of the change is not used,
+// as it might have been done, which fails the commit.
+func (g *GitClient) revert(id string) error {
+	rel, err := filepath.Rel(g.fs.dir, g.fs.path(id))
+	if err != nil {
+		return err
+	}
+	_, err = g.git(context.Background(), "checkout", "HEAD", "--", rel)
+	return err
+}
+
+func (g *GitClient) Update(ctx context.Context, id string, b []byte, version string) error {
+	_, err := g.change(ctx, "Update", func() (string, error) {
+		return id, g.fs.Update(ctx, id, b, version)
+	}, g.revert)
+	return err
+}
+
+func (g *GitClient) Patch(ctx context.Context, id string, patch []byte, version string) error {
+	_, err := g.change(ctx, "Patch", func() (string, error) {
+		return id, g.fs.Patch(ctx, id, patch, version)
+	}, g.revert)
+	return err
+}
+
+func (g *GitClient) Delete(ctx context.Context, id string, version string) error {
+	_, err := g.change(ctx, "Delete", func() (string, error) {
+		return id, g.fs.Delete(ctx, id, version)
+	}, g.revert)
+	return err
+}
+
+func (g *GitClient) List(ctx context.Context, withContent bool) ([]Object, error) {
+	return g.fs.List(ctx, withContent)
+}
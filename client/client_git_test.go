@@ -0,0 +1,115 @@
+package client
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func gitLog(t *testing.T, dir string, args ...string) []string {
+	out, err := exec.Command("git", append([]string{"-C", dir, "log"}, args...)...).Output()
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSpace(string(out)), "\n")
+}
+
+func TestGitClient(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+	c, err := NewGitClient(dir, &GitClientOption{
+		Extension:   ".json",
+		Pretty:      true,
+		AuthorName:  "Alice",
+		AuthorEmail: "alice@example.com",
+		Branch:      "state",
+	})
+	require.NoError(t, err)
+
+	id, err := c.Create(ctx, []byte(`{"a": 1}`))
+	require.NoError(t, err)
+	_, version, err := c.Read(ctx, id)
+	require.NoError(t, err)
+	require.NoError(t, c.Update(ctx, id, []byte(`{"a": 2}`), version))
+	// Updating to the same content makes no commit.
+	require.NoError(t, c.Update(ctx, id, []byte(`{"a": 2}`), ""))
+	require.NoError(t, c.Patch(ctx, id, []byte(`{"b": 1}`), ""))
+	require.ErrorIs(t, c.Update(ctx, id, []byte(`{"a": 3}`), version), ErrConflict)
+	require.NoError(t, c.Delete(ctx, id, ""))
+	require.ErrorIs(t, c.Delete(ctx, id, ""), ErrNotFound)
+
+	require.Equal(t, []string{
+		"Delete " + id,
+		"Patch " + id,
+		"Update " + id,
+		"Create " + id,
+	}, gitLog(t, dir, "--format=%s", "state"))
+	require.Equal(t, []string{"Alice <alice@example.com>"}, gitLog(t, dir, "--format=%an <%ae>", "-1"))
+
+	out, err := exec.Command("git", "-C", dir, "status", "--porcelain", "--untracked-files=all").Output()
+	require.NoError(t, err)
+	require.Empty(t, string(out), "the lock files are excluded")
+
+	// Reopening the repository on another branch, which is created from the current one.
+	c, err = NewGitClient(dir, &GitClientOption{Branch: "other"})
+	require.NoError(t, err)
+	id, err = c.Create(ctx, []byte(`{"a": 1}`))
+	require.NoError(t, err)
+	require.Len(t, gitLog(t, dir, "--format=%s", "other"), 5)
+	require.Len(t, gitLog(t, dir, "--format=%s", "state"), 4)
+
+	objs, err := c.List(ctx, false)
+	require.NoError(t, err)
+	require.Equal(t, []Object{{ID: id}}, objs)
+}
+
+func TestGitClientCommitFailure(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+	c, err := NewGitClient(dir, &GitClientOption{Extension: ".json"})
+	require.NoError(t, err)
+	id, err := c.Create(ctx, []byte(`{"a": 1}`))
+	require.NoError(t, err)
+
+	// The pre-commit hook fails every commit.
+	hook := filepath.Join(dir, ".git", "hooks", "pre-commit")
+	require.NoError(t, os.MkdirAll(filepath.Dir(hook), 0755))
+	require.NoError(t, os.WriteFile(hook, []byte("#!/bin/sh\nexit 1\n"), 0755))
+
+	// The created resource is removed, as its id is not returned.
+	_, err = c.Create(ctx, []byte(`{"a": 2}`))
+	require.ErrorContains(t, err, "committing the create")
+	objs, err := c.List(ctx, false)
+	require.NoError(t, err)
+	require.Equal(t, []Object{{ID: id}}, objs)
+	out, err := exec.Command("git", "-C", dir, "status", "--porcelain", "--untracked-files=all").Output()
+	require.NoError(t, err)
+	require.Empty(t, string(out), "nothing is left in the working tree or the index")
+
+	// The update, the patch and the deletion are reverted to the last commit.
+	require.ErrorContains(t, c.Update(ctx, id, []byte(`{"a": 3}`), ""), "committing the update")
+	require.ErrorContains(t, c.Patch(ctx, id, []byte(`{"b": 1}`), ""), "committing the patch")
+	require.ErrorContains(t, c.Delete(ctx, id, ""), "committing the delete")
+	b, _, err := c.Read(ctx, id)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"a": 1}`, string(b))
+	out, err = exec.Command("git", "-C", dir, "status", "--porcelain", "--untracked-files=all").Output()
+	require.NoError(t, err)
+	require.Empty(t, string(out), "nothing is left in the working tree or the index")
+
+	require.NoError(t, os.Remove(hook))
+	require.NoError(t, c.Patch(ctx, id, []byte(`{"b": 1}`), ""))
+	b, _, err = c.Read(ctx, id)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"a": 1, "b": 1}`, string(b))
+	require.Equal(t, []string{"Patch " + id, "Create " + id}, gitLog(t, dir, "--format=%s"))
+}
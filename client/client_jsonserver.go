@@ -2,128 +2,322 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"path"
 	"strconv"
+	"strings"
 )
 
+// defaultListPageSize is the number of resources requested per page when listing the collection.
+const defaultListPageSize = 100
+
 type JSONServerClient struct {
 	baseURL url.URL
+	idField string
+	*httpSender
+}
+
+type JSONServerClientOption struct {
+	// Retry configures how the failed requests are retried. Nil means no retry.
+	Retry *RetryOption
+	// Transport configures the HTTP client. Nil means using the http.DefaultClient.
+	Transport *TransportOption
+	// Auth configures how the requests are authenticated. Nil means no authentication.
+	Auth *AuthOption
+	// Compress makes the request bodies compressed by gzip, and asks for the gzip compressed responses.
+	Compress bool
+	// IDField is the name of the id field of the resources, whose value is either a number (json-server v0) or a
+	// string (json-server v1). Defaults to "id".
+	IDField string
+	// WireLog configures the redaction of the requests and the responses logged to the wire log subsystem. Nil means
+	// the default redaction.
+	WireLog *WireLogOption
 }
 
-func NewJSONServerClient(endpoint string) (Client, error) {
+func NewJSONServerClient(endpoint string, opt *JSONServerClientOption) (Client, error) {
 	baseURL, err := url.Parse(endpoint)
 	if err != nil {
 		return nil, err
 	}
+	if opt == nil {
+		opt = &JSONServerClientOption{}
+	}
+	sender, err := newHTTPSender(opt.Transport, opt.Retry, opt.Auth, opt.Compress, opt.WireLog)
+	if err != nil {
+		return nil, err
+	}
+	idField := opt.IDField
+	if idField == "" {
+		idField = "id"
+	}
 	return &JSONServerClient{
-		baseURL: *baseURL,
+		baseURL:    *baseURL,
+		idField:    idField,
+		httpSender: sender,
 	}, nil
 }
 
-func statuscodeMatches(code int, codes ...int) bool {
-	for _, okcode := range codes {
-		if okcode == code {
-			return true
-		}
+var _ CollectionClient = &JSONServerClient{}
+
+// Collection returns the client of the collection routed under the URL of this client, e.g. "/foos" of the json-server.
+func (j *JSONServerClient) Collection(name string) (Client, error) {
+	if err := checkCollectionName(name); err != nil {
+		return nil, err
 	}
-	return false
+	return &JSONServerClient{
+		baseURL:    joinPath(j.baseURL, name),
+		idField:    j.idField,
+		httpSender: j.httpSender,
+	}, nil
 }
 
-func (j *JSONServerClient) Create(b []byte) (string, error) {
-	resp, err := http.Post(j.baseURL.String(), "application/json", bytes.NewBuffer(b))
+func (j *JSONServerClient) Create(ctx context.Context, b []byte) (string, error) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	resp, err := j.do(ctx, "POST", j.baseURL, header, b)
 	if err != nil {
 		return "", fmt.Errorf("post: %w", err)
 	}
-	content, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
 	if !statuscodeMatches(resp.StatusCode, http.StatusOK, http.StatusCreated) {
-		return "", fmt.Errorf("unexpected status code: %d. Message: %s", resp.StatusCode, string(content))
+		return "", j.statusError(resp)
 	}
-	payload := map[string]interface{}{}
-	if err := json.Unmarshal(content, &payload); err != nil {
-		return "", err
+	payload, err := decodeJSON(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("decoding the create response: %v", err)
 	}
-	return strconv.Itoa(int(payload["id"].(float64))), nil
+	return idOf(payload, j.idField)
 }
 
-func (j *JSONServerClient) Read(id string) ([]byte, error) {
-	url := joinPath(j.baseURL, id)
-	resp, err := http.Get(url.String())
+func (j *JSONServerClient) Read(ctx context.Context, id string) ([]byte, string, error) {
+	resp, err := j.do(ctx, "GET", joinPath(j.baseURL, id), nil, nil)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	defer resp.Body.Close()
-
 	if statuscodeMatches(resp.StatusCode, http.StatusNotFound) {
-		return nil, ErrNotFound
-	}
-
-	content, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+		return nil, "", ErrNotFound
 	}
 	if !statuscodeMatches(resp.StatusCode, http.StatusOK) {
-		return nil, fmt.Errorf("unexpected status code: %d. Message: %s", resp.StatusCode, string(content))
+		return nil, "", j.statusError(resp)
 	}
-	return content, nil
+	return resp.Body, resp.Header.Get("ETag"), nil
 }
 
-func (j *JSONServerClient) Update(id string, b []byte) error {
-	url := joinPath(j.baseURL, id)
-	req, err := http.NewRequest("PUT", url.String(), bytes.NewBuffer(b))
-	if err != nil {
+func (j *JSONServerClient) Update(ctx context.Context, id string, b []byte, version string) error {
+	if err := j.checkVersion(ctx, id, version); err != nil {
 		return err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := http.DefaultClient.Do(req)
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	if version != "" {
+		header.Set("If-Match", version)
+	}
+	resp, err := j.do(ctx, "PUT", joinPath(j.baseURL, id), header, b)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	if statuscodeMatches(resp.StatusCode, http.StatusNotFound) {
+		return ErrNotFound
+	}
+	if statuscodeMatches(resp.StatusCode, http.StatusPreconditionFailed) {
+		return ErrConflict
+	}
+	if !statuscodeMatches(resp.StatusCode, http.StatusOK) {
+		return j.statusError(resp)
+	}
+	return nil
+}
 
-	content, err := io.ReadAll(resp.Body)
+func (j *JSONServerClient) Patch(ctx context.Context, id string, patch []byte, version string) error {
+	if err := j.checkVersion(ctx, id, version); err != nil {
+		return err
+	}
+	header := http.Header{}
+	// The RFC 7386 media type is "application/merge-patch+json", while the json-server only parses the body of
+	// "application/json".
+	header.Set("Content-Type", "application/json")
+	if version != "" {
+		header.Set("If-Match", version)
+	}
+	resp, err := j.do(ctx, "PATCH", joinPath(j.baseURL, id), header, patch)
 	if err != nil {
 		return err
 	}
+	if statuscodeMatches(resp.StatusCode, http.StatusNotFound) {
+		return ErrNotFound
+	}
+	if statuscodeMatches(resp.StatusCode, http.StatusPreconditionFailed) {
+		return ErrConflict
+	}
 	if !statuscodeMatches(resp.StatusCode, http.StatusOK) {
-		return fmt.Errorf("unexpected status code: %d. Message: %s", resp.StatusCode, string(content))
+		return j.statusError(resp)
 	}
 	return nil
 }
 
-func (j *JSONServerClient) Delete(id string) error {
-	url := joinPath(j.baseURL, id)
-	req, err := http.NewRequest("DELETE", url.String(), nil)
-	if err != nil {
+func (j *JSONServerClient) Delete(ctx context.Context, id string, version string) error {
+	if err := j.checkVersion(ctx, id, version); err != nil {
 		return err
 	}
-	resp, err := http.DefaultClient.Do(req)
+	header := http.Header{}
+	if version != "" {
+		header.Set("If-Match", version)
+	}
+	resp, err := j.do(ctx, "DELETE", joinPath(j.baseURL, id), header, nil)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
 	if statuscodeMatches(resp.StatusCode, http.StatusNotFound) {
 		return ErrNotFound
 	}
+	if statuscodeMatches(resp.StatusCode, http.StatusPreconditionFailed) {
+		return ErrConflict
+	}
+	if !statuscodeMatches(resp.StatusCode, http.StatusOK) {
+		return j.statusError(resp)
+	}
+	return nil
+}
+
+func (j *JSONServerClient) List(ctx context.Context, withContent bool) ([]Object, error) {
+	u := j.baseURL
+	q := u.Query()
+	q.Set("_page", "1")
+	// The json-server v0 takes the "_limit", while the v1 takes the "_per_page".
+	q.Set("_limit", strconv.Itoa(defaultListPageSize))
+	q.Set("_per_page", strconv.Itoa(defaultListPageSize))
+	u.RawQuery = q.Encode()
+
+	var objects []Object
+	next := &u
+	for next != nil {
+		items, nextPage, err := j.listPage(ctx, *next)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			payload, err := decodeJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			id, err := idOf(payload, j.idField)
+			if err != nil {
+				return nil, err
+			}
+			obj := Object{ID: id}
+			if withContent {
+				obj.Content = item
+			}
+			objects = append(objects, obj)
+		}
+		next = nextPage
+	}
+	return objects, nil
+}
 
-	content, err := io.ReadAll(resp.Body)
+// checkVersion returns ErrConflict if version is not empty and doesn't match the current ETag of the resource.
+// The json-server doesn't honor the "If-Match" header, so we have to compare the ETag beforehand. The "If-Match"
+// header is still sent along with the request, for the servers that do honor it.
+func (j *JSONServerClient) checkVersion(ctx context.Context, id string, version string) error {
+	if version == "" {
+		return nil
+	}
+	_, current, err := j.Read(ctx, id)
 	if err != nil {
 		return err
 	}
-	if !statuscodeMatches(resp.StatusCode, http.StatusOK) {
-		return fmt.Errorf("unexpected status code: %d. Message: %s", resp.StatusCode, string(content))
+	if current != version {
+		return ErrConflict
 	}
 	return nil
 }
 
+// listPage gets one page of the collection, returns the raw items and the URL of the next page, if any.
+// The json-server v0 responds the array of the items, with the link to the next page in the "Link" header. The v1
+// responds an object, with the items in "data" and the number of the next page in "next".
+func (j *JSONServerClient) listPage(ctx context.Context, u url.URL) ([]json.RawMessage, *url.URL, error) {
+	resp, err := j.do(ctx, "GET", u, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !statuscodeMatches(resp.StatusCode, http.StatusOK) {
+		return nil, nil, j.statusError(resp)
+	}
+	if body := bytes.TrimSpace(resp.Body); len(body) != 0 && body[0] == '{' {
+		var page struct {
+			Data []json.RawMessage `json:"data"`
+			Next *int              `json:"next"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, nil, err
+		}
+		if page.Next == nil {
+			return page.Data, nil, nil
+		}
+		q := u.Query()
+		q.Set("_page", strconv.Itoa(*page.Next))
+		u.RawQuery = q.Encode()
+		return page.Data, &u, nil
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(resp.Body, &items); err != nil {
+		return nil, nil, err
+	}
+	nextLink, ok := parseLinkHeader(resp.Header.Get("Link"))["next"]
+	if !ok {
+		return items, nil, nil
+	}
+	next, err := u.Parse(nextLink)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing next link %q: %v", nextLink, err)
+	}
+	return items, next, nil
+}
+
+// parseLinkHeader parses the RFC 8288 "Link" header, returns a map from the relation type to the target URL.
+// E.g. `<http://localhost/posts?_page=2>; rel="next", <http://localhost/posts?_page=5>; rel="last"`
+func parseLinkHeader(header string) map[string]string {
+	links := map[string]string{}
+	for _, link := range strings.Split(header, ",") {
+		segs := strings.Split(link, ";")
+		target := strings.TrimSpace(segs[0])
+		if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+		target = strings.TrimSuffix(strings.TrimPrefix(target, "<"), ">")
+		for _, param := range segs[1:] {
+			k, v, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.TrimSpace(k) != "rel" {
+				continue
+			}
+			for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(v), `"`)) {
+				links[rel] = target
+			}
+		}
+	}
+	return links
+}
+
+// idOf returns the id of the resource from the field of its decoded payload.
+func idOf(payload interface{}, field string) (string, error) {
+	m, ok := payload.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("the payload is not an object")
+	}
+	v, ok := m[field]
+	if !ok {
+		return "", fmt.Errorf("no %q in the payload", field)
+	}
+	id, err := idString(v)
+	if err != nil {
+		return "", fmt.Errorf("invalid %q in the payload: %v", field, err)
+	}
+	return id, nil
+}
+
 func joinPath(base url.URL, p string) url.URL {
 	base.Path = path.Join(base.Path, p)
 	return base
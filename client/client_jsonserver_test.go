@@ -1,12 +1,18 @@
 package client
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"path"
+	"sort"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"testing"
 
@@ -16,6 +22,38 @@ import (
 type testHandler struct {
 	i   uint64
 	buf map[string]map[string]interface{}
+	// ignoreIfMatch mimics the json-server, which doesn't honor the "If-Match" header.
+	ignoreIfMatch bool
+	// v1 mimics the json-server v1, which uses the string ids, and responds the pagination in the body.
+	v1 bool
+	// idField is the name of the id field. Defaults to "id".
+	idField string
+}
+
+func (h *testHandler) field() string {
+	if h.idField == "" {
+		return "id"
+	}
+	return h.idField
+}
+
+func testETag(m map[string]interface{}) string {
+	b, _ := json.Marshal(m)
+	return fmt.Sprintf(`W/"%x"`, sha256.Sum256(b))
+}
+
+// preconditionFailed checks the "If-Match" header against the ETag of the resource, and writes a 412 response
+// if it doesn't match.
+func (h *testHandler) preconditionFailed(w http.ResponseWriter, r *http.Request, m map[string]interface{}) bool {
+	if h.ignoreIfMatch {
+		return false
+	}
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" || ifMatch == testETag(m) {
+		return false
+	}
+	w.WriteHeader(412)
+	return true
 }
 
 func (h *testHandler) Handle(w http.ResponseWriter, r *http.Request) {
@@ -34,9 +72,14 @@ func (h *testHandler) Handle(w http.ResponseWriter, r *http.Request) {
 			w.Write([]byte(fmt.Sprintf("unmarshal request: %v", err)))
 			return
 		}
-		id := atomic.AddUint64(&h.i, 1)
-		m["id"] = id
-		url := joinPath(*r.URL, strconv.FormatUint(id, 10))
+		n := atomic.AddUint64(&h.i, 1)
+		id := strconv.FormatUint(n, 10)
+		m[h.field()] = n
+		if h.v1 {
+			id = fmt.Sprintf("%04x", n)
+			m[h.field()] = id
+		}
+		url := joinPath(*r.URL, id)
 		h.buf[url.Path] = m
 		b, err = json.Marshal(m)
 		if err != nil {
@@ -48,6 +91,10 @@ func (h *testHandler) Handle(w http.ResponseWriter, r *http.Request) {
 		w.Write(b)
 		return
 	case http.MethodGet:
+		if r.URL.Query().Has("_page") {
+			h.list(w, r)
+			return
+		}
 		m, ok := h.buf[r.URL.Path]
 		if !ok {
 			w.WriteHeader(404)
@@ -59,6 +106,7 @@ func (h *testHandler) Handle(w http.ResponseWriter, r *http.Request) {
 			w.Write([]byte(fmt.Sprintf("marshal response: %v", err)))
 			return
 		}
+		w.Header().Set("ETag", testETag(m))
 		w.Write(b)
 		return
 	case http.MethodPut:
@@ -67,7 +115,10 @@ func (h *testHandler) Handle(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(404)
 			return
 		}
-		id := om["id"]
+		if h.preconditionFailed(w, r, om) {
+			return
+		}
+		id := om[h.field()]
 		b, err := io.ReadAll(r.Body)
 		if err != nil {
 			w.WriteHeader(500)
@@ -81,37 +132,453 @@ func (h *testHandler) Handle(w http.ResponseWriter, r *http.Request) {
 			w.Write([]byte(fmt.Sprintf("unmarshal request: %v", err)))
 			return
 		}
-		m["id"] = id
+		m[h.field()] = id
 		h.buf[r.URL.Path] = m
 		w.Write(b)
 		return
+	case http.MethodPatch:
+		m, ok := h.buf[r.URL.Path]
+		if !ok {
+			w.WriteHeader(404)
+			return
+		}
+		if h.preconditionFailed(w, r, m) {
+			return
+		}
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(fmt.Sprintf("reading request: %v", err)))
+			return
+		}
+		r.Body.Close()
+		patch := map[string]interface{}{}
+		if err := json.Unmarshal(b, &patch); err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte(fmt.Sprintf("unmarshal request: %v", err)))
+			return
+		}
+		// Only merge the top level members, which is enough for the tests.
+		for k, v := range patch {
+			if k == h.field() {
+				continue
+			}
+			if v == nil {
+				delete(m, k)
+				continue
+			}
+			m[k] = v
+		}
+		b, err = json.Marshal(m)
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(fmt.Sprintf("marshal response: %v", err)))
+			return
+		}
+		w.Write(b)
+		return
 	case http.MethodDelete:
-		if _, ok := h.buf[r.URL.Path]; !ok {
+		om, ok := h.buf[r.URL.Path]
+		if !ok {
 			w.WriteHeader(404)
 			return
 		}
+		if h.preconditionFailed(w, r, om) {
+			return
+		}
 		delete(h.buf, r.URL.Path)
 		w.WriteHeader(200)
 		return
 	}
 }
 
+// list mimics the json-server pagination, which returns the items of the requested page, together with the
+// links to the other pages in the "Link" header (v0), or in the body (v1).
+func (h *testHandler) list(w http.ResponseWriter, r *http.Request) {
+	page, err := strconv.Atoi(r.URL.Query().Get("_page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limitParam := "_limit"
+	if h.v1 {
+		limitParam = "_per_page"
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get(limitParam))
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+	var items []map[string]interface{}
+	for k, m := range h.buf {
+		if path.Dir(k) == r.URL.Path {
+			items = append(items, m)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return fmt.Sprint(items[i][h.field()]) < fmt.Sprint(items[j][h.field()])
+	})
+	last := (len(items) + limit - 1) / limit
+	if last < 1 {
+		last = 1
+	}
+	pageURL := func(page int) string {
+		u := *r.URL
+		q := u.Query()
+		q.Set("_page", strconv.Itoa(page))
+		u.RawQuery = q.Encode()
+		return "http://" + r.Host + u.String()
+	}
+	start, end := (page-1)*limit, page*limit
+	if start > len(items) {
+		start = len(items)
+	}
+	if end > len(items) {
+		end = len(items)
+	}
+	var body interface{} = append([]map[string]interface{}{}, items[start:end]...)
+	if h.v1 {
+		var next interface{}
+		if page < last {
+			next = page + 1
+		}
+		body = map[string]interface{}{
+			"first": 1,
+			"next":  next,
+			"last":  last,
+			"pages": last,
+			"items": len(items),
+			"data":  body,
+		}
+	} else {
+		links := []string{fmt.Sprintf(`<%s>; rel="first"`, pageURL(1))}
+		if page < last {
+			links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageURL(page+1)))
+		}
+		links = append(links, fmt.Sprintf(`<%s>; rel="last"`, pageURL(last)))
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte(fmt.Sprintf("marshal response: %v", err)))
+		return
+	}
+	w.Write(b)
+}
+
 func TestClientJSONServer(t *testing.T) {
 	h := testHandler{
 		buf: map[string]map[string]interface{}{},
 	}
 	ts := httptest.NewServer(http.HandlerFunc(h.Handle))
 	defer ts.Close()
-	c, _ := NewJSONServerClient(ts.URL + "/posts")
+	c, _ := NewJSONServerClient(ts.URL+"/posts", nil)
+	ctx := context.Background()
 
-	id, err := c.Create([]byte(`{"name": "foo"}`))
+	id, err := c.Create(ctx, []byte(`{"name": "foo"}`))
 	require.NoError(t, err, "create failed")
-	got, err := c.Read(id)
+	got, _, err := c.Read(ctx, id)
 	require.JSONEq(t, `{"id": 1, "name": "foo"}`, string(got), "read after creation")
-	require.NoError(t, c.Update(id, []byte(`{"name": "bar"}`)), "update failed")
-	got, err = c.Read(id)
+	require.NoError(t, c.Update(ctx, id, []byte(`{"name": "bar"}`), ""), "update failed")
+	got, _, err = c.Read(ctx, id)
 	require.JSONEq(t, `{"id": 1, "name": "bar"}`, string(got), "read after update")
-	require.NoError(t, c.Delete(id), "delete failed")
-	_, err = c.Read(id)
+	require.NoError(t, c.Delete(ctx, id, ""), "delete failed")
+	_, _, err = c.Read(ctx, id)
 	require.Equal(t, ErrNotFound, err, "read non existent resource should return ErrNotFound")
 }
+
+func TestClientJSONServerCanceled(t *testing.T) {
+	h := testHandler{
+		buf: map[string]map[string]interface{}{},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(h.Handle))
+	defer ts.Close()
+	c, _ := NewJSONServerClient(ts.URL+"/posts", nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := c.Create(ctx, []byte(`{"name": "foo"}`))
+	require.ErrorIs(t, err, context.Canceled, "create with canceled context")
+}
+
+func TestClientJSONServerList(t *testing.T) {
+	h := testHandler{
+		buf: map[string]map[string]interface{}{},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(h.Handle))
+	defer ts.Close()
+	c, _ := NewJSONServerClient(ts.URL+"/posts", nil)
+	ctx := context.Background()
+
+	objs, err := c.List(ctx, false)
+	require.NoError(t, err, "list empty collection")
+	require.Empty(t, objs, "list empty collection")
+
+	// Create more resources than a single page can hold.
+	var ids []string
+	for i := 0; i < defaultListPageSize+5; i++ {
+		id, err := c.Create(ctx, []byte(fmt.Sprintf(`{"name": "foo%d"}`, i)))
+		require.NoError(t, err, "create failed")
+		ids = append(ids, id)
+	}
+	objs, err = c.List(ctx, true)
+	require.NoError(t, err, "list failed")
+	var gotIds []string
+	for _, obj := range objs {
+		gotIds = append(gotIds, obj.ID)
+		b, _, err := c.Read(ctx, obj.ID)
+		require.NoError(t, err, "read failed")
+		require.JSONEq(t, string(b), string(obj.Content), "listed content")
+	}
+	require.ElementsMatch(t, ids, gotIds, "listed ids")
+}
+
+func TestClientJSONServerVersion(t *testing.T) {
+	for _, ignoreIfMatch := range []bool{false, true} {
+		t.Run(fmt.Sprintf("ignoreIfMatch=%t", ignoreIfMatch), func(t *testing.T) {
+			h := testHandler{
+				buf:           map[string]map[string]interface{}{},
+				ignoreIfMatch: ignoreIfMatch,
+			}
+			ts := httptest.NewServer(http.HandlerFunc(h.Handle))
+			defer ts.Close()
+			c, _ := NewJSONServerClient(ts.URL+"/posts", nil)
+			ctx := context.Background()
+
+			id, err := c.Create(ctx, []byte(`{"name": "foo"}`))
+			require.NoError(t, err, "create failed")
+			_, version, err := c.Read(ctx, id)
+			require.NoError(t, err, "read failed")
+			require.NotEmpty(t, version, "version is the ETag")
+
+			// Someone else updates the resource.
+			require.NoError(t, c.Update(ctx, id, []byte(`{"name": "bar"}`), ""), "blind update failed")
+			require.Equal(t, ErrConflict, c.Update(ctx, id, []byte(`{"name": "baz"}`), version), "update with stale version")
+			require.Equal(t, ErrConflict, c.Delete(ctx, id, version), "delete with stale version")
+
+			_, version, err = c.Read(ctx, id)
+			require.NoError(t, err, "read failed")
+			require.NoError(t, c.Update(ctx, id, []byte(`{"name": "baz"}`), version), "update with current version")
+			_, version, err = c.Read(ctx, id)
+			require.NoError(t, err, "read failed")
+			require.NoError(t, c.Delete(ctx, id, version), "delete with current version")
+		})
+	}
+}
+
+func TestClientJSONServerPatch(t *testing.T) {
+	h := testHandler{
+		buf: map[string]map[string]interface{}{},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(h.Handle))
+	defer ts.Close()
+	c, _ := NewJSONServerClient(ts.URL+"/posts", nil)
+	ctx := context.Background()
+
+	id, err := c.Create(ctx, []byte(`{"name": "foo", "age": 1, "extra": "server side"}`))
+	require.NoError(t, err, "create failed")
+	_, version, err := c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.NoError(t, c.Patch(ctx, id, []byte(`{"name": "bar", "age": null}`), version), "patch failed")
+	got, _, err := c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.JSONEq(t, `{"id": 1, "name": "bar", "extra": "server side"}`, string(got), "read after patch")
+
+	require.Equal(t, ErrConflict, c.Patch(ctx, id, []byte(`{"name": "baz"}`), version), "patch with stale version")
+	require.Equal(t, ErrNotFound, c.Patch(ctx, "100", []byte(`{"name": "baz"}`), ""), "patch non existent resource")
+	require.Equal(t, ErrNotFound, c.Update(ctx, "100", []byte(`{"name": "baz"}`), ""), "update non existent resource")
+}
+
+func TestClientJSONServerIDs(t *testing.T) {
+	cases := []struct {
+		name    string
+		v1      bool
+		idField string
+	}{
+		{name: "v0"},
+		{name: "v1", v1: true},
+		{name: "v0 custom id field", idField: "uuid"},
+		{name: "v1 custom id field", v1: true, idField: "uuid"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			h := testHandler{
+				buf:     map[string]map[string]interface{}{},
+				v1:      tt.v1,
+				idField: tt.idField,
+			}
+			ts := httptest.NewServer(http.HandlerFunc(h.Handle))
+			defer ts.Close()
+			c, err := NewJSONServerClient(ts.URL+"/posts", &JSONServerClientOption{IDField: tt.idField})
+			require.NoError(t, err)
+			ctx := context.Background()
+
+			var ids []string
+			for i := 0; i < defaultListPageSize+5; i++ {
+				id, err := c.Create(ctx, []byte(fmt.Sprintf(`{"name": "foo%d"}`, i)))
+				require.NoError(t, err, "create failed")
+				ids = append(ids, id)
+			}
+			if tt.v1 {
+				require.Equal(t, "0001", ids[0], "string id")
+			} else {
+				require.Equal(t, "1", ids[0], "numeric id")
+			}
+			require.NoError(t, c.Update(ctx, ids[0], []byte(`{"name": "bar"}`), ""), "update failed")
+			got, _, err := c.Read(ctx, ids[0])
+			require.NoError(t, err, "read failed")
+			require.Equal(t, "bar", decodeObject(t, got)["name"], "read after update")
+
+			objs, err := c.List(ctx, false)
+			require.NoError(t, err, "list failed")
+			var gotIds []string
+			for _, obj := range objs {
+				gotIds = append(gotIds, obj.ID)
+			}
+			require.ElementsMatch(t, ids, gotIds, "listed ids")
+		})
+	}
+}
+
+func decodeObject(t *testing.T, b []byte) map[string]interface{} {
+	m := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(b, &m))
+	return m
+}
+
+func TestClientJSONServerInvalidID(t *testing.T) {
+	for _, resp := range []string{`{}`, `{"id": null}`, `{"id": ""}`, `{"id": true}`, `[]`, `not json`} {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(resp))
+		}))
+		c, err := NewJSONServerClient(ts.URL+"/posts", nil)
+		require.NoError(t, err)
+		_, err = c.Create(context.Background(), []byte(`{}`))
+		require.Error(t, err, resp)
+		ts.Close()
+	}
+}
+
+func TestClientJSONServerCollection(t *testing.T) {
+	h := testHandler{
+		buf: map[string]map[string]interface{}{},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(h.Handle))
+	defer ts.Close()
+	c, err := NewJSONServerClient(ts.URL+"/api", nil)
+	require.NoError(t, err)
+	foos, err := c.(CollectionClient).Collection("foos")
+	require.NoError(t, err)
+	bars, err := c.(CollectionClient).Collection("bars")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	fooID, err := foos.Create(ctx, []byte(`{"name": "foo"}`))
+	require.NoError(t, err)
+	barID, err := bars.Create(ctx, []byte(`{"name": "bar"}`))
+	require.NoError(t, err)
+	require.Contains(t, h.buf, "/api/foos/"+fooID)
+	require.Contains(t, h.buf, "/api/bars/"+barID)
+
+	objs, err := foos.List(ctx, false)
+	require.NoError(t, err)
+	require.Equal(t, []Object{{ID: fooID}}, objs)
+	_, _, err = bars.Read(ctx, fooID)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, err = c.(CollectionClient).Collection("a/b")
+	require.Error(t, err)
+}
+
+func TestParseLinkHeader(t *testing.T) {
+	require.Equal(t,
+		map[string]string{
+			"first": "http://localhost/posts?_page=1",
+			"next":  "http://localhost/posts?_page=3",
+			"prev":  "http://localhost/posts?_page=1",
+			"last":  "http://localhost/posts?_page=5",
+		},
+		parseLinkHeader(`<http://localhost/posts?_page=1>; rel="first", <http://localhost/posts?_page=1>; rel="prev", <http://localhost/posts?_page=3>; rel="next", <http://localhost/posts?_page=5>; rel="last"`),
+	)
+	require.Empty(t, parseLinkHeader(""))
+}
+
+// gzipHandler mimics the server supporting the gzip encoding, in front of the testHandler. It records the number of
+// requests that are gzip compressed.
+type gzipHandler struct {
+	h          *testHandler
+	compressed int64
+}
+
+func (g *gzipHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		b, err := io.ReadAll(r.Body)
+		if err != nil || !isCompressed(b) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if b, err = decompress(b); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		atomic.AddInt64(&g.compressed, 1)
+		r.Body = io.NopCloser(bytes.NewReader(b))
+		r.Header.Del("Content-Encoding")
+	}
+	if r.Header.Get("Accept-Encoding") != "gzip" {
+		g.h.Handle(w, r)
+		return
+	}
+	rec := httptest.NewRecorder()
+	g.h.Handle(rec, r)
+	b, err := compress(rec.Body.Bytes())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	for k, v := range rec.Header() {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Content-Encoding", "gzip")
+	w.WriteHeader(rec.Code)
+	w.Write(b)
+}
+
+func TestClientJSONServerCompress(t *testing.T) {
+	g := &gzipHandler{
+		h: &testHandler{
+			buf: map[string]map[string]interface{}{},
+		},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(g.Handle))
+	defer ts.Close()
+	c, _ := NewJSONServerClient(ts.URL+"/posts", &JSONServerClientOption{Compress: true})
+	ctx := context.Background()
+
+	id, err := c.Create(ctx, []byte(`{"name": "foo"}`))
+	require.NoError(t, err, "create failed")
+	got, version, err := c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.JSONEq(t, `{"id": 1, "name": "foo"}`, string(got), "read the decompressed response")
+	require.NoError(t, c.Update(ctx, id, []byte(`{"name": "bar"}`), version), "update failed")
+	_, version, err = c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.NoError(t, c.Patch(ctx, id, []byte(`{"age": 1}`), version), "patch failed")
+	got, _, err = c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.JSONEq(t, `{"id": 1, "name": "bar", "age": 1}`, string(got), "read after update")
+	require.Equal(t, int64(3), atomic.LoadInt64(&g.compressed), "the request bodies are compressed")
+
+	objs, err := c.List(ctx, true)
+	require.NoError(t, err, "list failed")
+	require.Len(t, objs, 1)
+	require.JSONEq(t, `{"id": 1, "name": "bar", "age": 1}`, string(objs[0].Content), "list the decompressed response")
+
+	// The server not supporting gzip responds with the plain content.
+	ts2 := httptest.NewServer(http.HandlerFunc(g.h.Handle))
+	defer ts2.Close()
+	c, _ = NewJSONServerClient(ts2.URL+"/posts", &JSONServerClientOption{Compress: true})
+	got, _, err = c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.JSONEq(t, `{"id": 1, "name": "bar", "age": 1}`, string(got), "read the plain response")
+}
@@ -0,0 +1,579 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/spf13/afero"
+)
+
+// logStoreMagic is the header of the log file.
+const logStoreMagic = "DEMOLOG1"
+
+// The log file consists of the header followed by the records, each of which is in the form of:
+//
+//	crc32 (4) | op (1) | id length (4) | content length (4) | id | content
+//
+// The integers are little endian. The checksum is the CRC-32C of everything after it.
+const logRecordHeaderSize = 4 + 1 + 4 + 4
+
+const (
+	logOpPut    byte = 1
+	logOpDelete byte = 2
+)
+
+const (
+	defaultCompactionRatio   = 0.5
+	defaultCompactionMinSize = 1 << 20
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// LogStoreClient stores all the resources in a single append-only log file. Each create, update or delete appends a
+// record to the log, and the index of the live resources is kept in memory, which is rebuilt by replaying the log on
+// open. The stale records are dropped by compacting the log, which rewrites the live records to a new log file. The
+// compaction is not run in the background, but checked on open and after each write, or run explicitly by Compact.
+//
+// The log file can be shared by multiple processes, the changes appended by the others are replayed before each
+// operation.
+type LogStoreClient struct {
+	fs          afero.Fs
+	path        string
+	locker      locker
+	lockTimeout time.Duration
+
+	compactionRatio   float64
+	compactionMinSize int64
+
+	// mu guards the fields below, which reflect the log file up to size.
+	mu      sync.Mutex
+	file    afero.File
+	info    os.FileInfo
+	size    int64
+	garbage int64
+	index   map[string]logEntry
+}
+
+// logEntry locates the live record of a resource in the log file.
+type logEntry struct {
+	// offset is where the record starts.
+	offset int64
+	// size is the size of the whole record.
+	size int64
+	// contentOffset and contentLength locate the content in the log file.
+	contentOffset int64
+	contentLength int64
+}
+
+type LogStoreClientOption struct {
+	// LockTimeout is the maximum time to wait for acquiring the lock of the log file. Zero means waiting until the
+	// context is done.
+	LockTimeout time.Duration
+	// CompactionRatio is the ratio of the stale records in the log file, above which the log file is compacted
+	// on open or after a write. Defaults to 0.5. Negative value disables the automatic compaction.
+	CompactionRatio float64
+	// CompactionMinSize is the minimum size of the log file to be compacted automatically. Defaults to 1MiB.
+	CompactionMinSize int64
+}
+
+func NewLogStoreClient(path string, opt *LogStoreClientOption) (Client, error) {
+	l, err := newLogStoreClient(afero.NewOsFs(), path, opt)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func newLogStoreClient(fs afero.Fs, path string, opt *LogStoreClientOption) (*LogStoreClient, error) {
+	if opt == nil {
+		opt = &LogStoreClientOption{}
+	}
+	l := &LogStoreClient{
+		fs:                fs,
+		path:              path,
+		lockTimeout:       opt.LockTimeout,
+		compactionRatio:   opt.CompactionRatio,
+		compactionMinSize: opt.CompactionMinSize,
+	}
+	if l.compactionRatio == 0 {
+		l.compactionRatio = defaultCompactionRatio
+	}
+	if l.compactionMinSize == 0 {
+		l.compactionMinSize = defaultCompactionMinSize
+	}
+	dir := filepath.Dir(path)
+	if err := fs.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	// The flock based locker only works for the OS filesystem.
+	l.locker = newMemLocker()
+	if _, ok := fs.(*afero.OsFs); ok {
+		var err error
+		if l.locker, err = newOsLocker(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	unlock, err := l.lock(context.Background(), true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err := l.recover(); err != nil {
+		return nil, fmt.Errorf("recovering %s: %v", path, err)
+	}
+	// The log file might have grown stale by the processes with the automatic compaction disabled.
+	if err := l.maybeCompact(); err != nil {
+		return nil, fmt.Errorf("compacting %s: %v", path, err)
+	}
+	return l, nil
+}
+
+// lock acquires the lock of the log file, and then replays the records appended by the others. The exclusive lock
+// is required for writing the log file.
+func (l *LogStoreClient) lock(ctx context.Context, exclusive bool) (func(), error) {
+	unlockFile, err := acquireLock(ctx, l.locker, filepath.Base(l.path), exclusive, l.lockTimeout)
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	unlock := func() {
+		l.mu.Unlock()
+		unlockFile()
+	}
+	if err := l.refresh(); err != nil {
+		unlock()
+		return nil, err
+	}
+	return unlock, nil
+}
+
+// recover removes the temporary files left over by the interrupted compaction, and truncates the partially written
+// record at the end of the log file, if any. The exclusive lock is expected to be held.
+func (l *LogStoreClient) recover() error {
+	tmps, err := afero.Glob(l.fs, filepath.Join(filepath.Dir(l.path), tmpFilePrefix+filepath.Base(l.path)+"-*"))
+	if err != nil {
+		return err
+	}
+	for _, tmp := range tmps {
+		if err := l.fs.Remove(tmp); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return l.truncate()
+}
+
+// truncate truncates the log file to the last complete record. The exclusive lock is expected to be held.
+func (l *LogStoreClient) truncate() error {
+	info, err := l.file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == l.size {
+		return nil
+	}
+	if err := l.file.Truncate(l.size); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// refresh brings the index up to date with the log file. The log file is replayed from where it was left, or from
+// the beginning if it has been replaced by the compaction of the others.
+func (l *LogStoreClient) refresh() error {
+	info, err := l.fs.Stat(l.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if l.file == nil || info == nil || info.Size() < l.size || !l.sameFile(info) {
+		if err := l.open(); err != nil {
+			return err
+		}
+		if info, err = l.file.Stat(); err != nil {
+			return err
+		}
+	}
+	l.info = info
+	if info.Size() > l.size {
+		return l.replay()
+	}
+	return nil
+}
+
+// sameFile tells whether the log file is still the one opened. It can only be told for the OS filesystem, while the
+// log file can't be shared by the processes otherwise.
+func (l *LogStoreClient) sameFile(info os.FileInfo) bool {
+	if _, ok := l.fs.(*afero.OsFs); !ok {
+		return true
+	}
+	return os.SameFile(l.info, info)
+}
+
+// open (re)opens the log file, and resets the index. The log file is created with the header if not exists.
+func (l *LogStoreClient) open() error {
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+	file, err := l.fs.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if info.Size() == 0 {
+		// The header is written without the exclusive lock in case of the read, which is fine as every process
+		// writes the same header.
+		if _, err := file.WriteAt([]byte(logStoreMagic), 0); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return err
+		}
+	} else {
+		header := make([]byte, len(logStoreMagic))
+		if _, err := file.ReadAt(header, 0); err != nil || string(header) != logStoreMagic {
+			file.Close()
+			return fmt.Errorf("%s is not a log store file", l.path)
+		}
+	}
+	l.file = file
+	l.info = info
+	l.size = int64(len(logStoreMagic))
+	l.garbage = 0
+	l.index = map[string]logEntry{}
+	return nil
+}
+
+// replay applies the records after the current size to the index. It stops at the partially written record at the
+// end, which is left by an interrupted write.
+func (l *LogStoreClient) replay() error {
+	r := io.NewSectionReader(l.file, l.size, l.info.Size()-l.size)
+	header := make([]byte, logRecordHeaderSize)
+	for {
+		offset := l.size
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil
+			}
+			return err
+		}
+		op := header[4]
+		idLen := int64(binary.LittleEndian.Uint32(header[5:9]))
+		contentLen := int64(binary.LittleEndian.Uint32(header[9:13]))
+		size := logRecordHeaderSize + idLen + contentLen
+		if offset+size > l.info.Size() {
+			return nil
+		}
+		body := make([]byte, idLen+contentLen)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return err
+		}
+		crc := crc32.Update(crc32.Checksum(header[4:], crc32c), crc32c, body)
+		if crc != binary.LittleEndian.Uint32(header[:4]) {
+			if offset+size == l.info.Size() {
+				// The last record might be partially written.
+				return nil
+			}
+			return fmt.Errorf("corrupted record at offset %d of %s", offset, l.path)
+		}
+		id := string(body[:idLen])
+		if old, ok := l.index[id]; ok {
+			l.garbage += old.size
+		}
+		switch op {
+		case logOpPut:
+			l.index[id] = logEntry{
+				offset:        offset,
+				size:          size,
+				contentOffset: offset + logRecordHeaderSize + idLen,
+				contentLength: contentLen,
+			}
+		case logOpDelete:
+			delete(l.index, id)
+			l.garbage += size
+		default:
+			return fmt.Errorf("unknown operation %d of the record at offset %d of %s", op, offset, l.path)
+		}
+		l.size += size
+	}
+}
+
+func encodeLogRecord(op byte, id string, content []byte) []byte {
+	var buf bytes.Buffer
+	header := make([]byte, logRecordHeaderSize)
+	header[4] = op
+	binary.LittleEndian.PutUint32(header[5:9], uint32(len(id)))
+	binary.LittleEndian.PutUint32(header[9:13], uint32(len(content)))
+	crc := crc32.Checksum(header[4:], crc32c)
+	crc = crc32.Update(crc, crc32c, []byte(id))
+	crc = crc32.Update(crc, crc32c, content)
+	binary.LittleEndian.PutUint32(header[:4], crc)
+	buf.Write(header)
+	buf.WriteString(id)
+	buf.Write(content)
+	return buf.Bytes()
+}
+
+// append appends the record to the log file, and then applies it to the index. The exclusive lock is expected to
+// be held.
+func (l *LogStoreClient) append(op byte, id string, content []byte) error {
+	// Drop the partially written record left by an interrupted write, if any.
+	if err := l.truncate(); err != nil {
+		return err
+	}
+	rec := encodeLogRecord(op, id, content)
+	if _, err := l.file.WriteAt(rec, l.size); err != nil {
+		return err
+	}
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+	info, err := l.file.Stat()
+	if err != nil {
+		return err
+	}
+	l.info = info
+	if err := l.replay(); err != nil {
+		return err
+	}
+	return l.maybeCompact()
+}
+
+// maybeCompact compacts the log file if there are too many stale records.
+func (l *LogStoreClient) maybeCompact() error {
+	if l.compactionRatio < 0 || l.size < l.compactionMinSize {
+		return nil
+	}
+	if float64(l.garbage)/float64(l.size) < l.compactionRatio {
+		return nil
+	}
+	return l.compact()
+}
+
+// Compact rewrites the live records to a new log file, which then replaces the current one.
+func (l *LogStoreClient) Compact(ctx context.Context) error {
+	unlock, err := l.lock(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return l.compact()
+}
+
+// compact compacts the log file, the exclusive lock is expected to be held.
+func (l *LogStoreClient) compact() (err error) {
+	tmp, err := afero.TempFile(l.fs, filepath.Dir(l.path), tmpFilePrefix+filepath.Base(l.path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			l.fs.Remove(tmp.Name())
+		}
+	}()
+	if _, err := tmp.Write([]byte(logStoreMagic)); err != nil {
+		return err
+	}
+	for _, id := range l.ids() {
+		entry := l.index[id]
+		rec := make([]byte, entry.size)
+		if _, err := l.file.ReadAt(rec, entry.offset); err != nil {
+			return err
+		}
+		if _, err := tmp.Write(rec); err != nil {
+			return err
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := l.fs.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := l.fs.Rename(tmp.Name(), l.path); err != nil {
+		return err
+	}
+	// Sync the directory to persist the rename. This is best effort, as it is not supported on all platforms.
+	if d, err := l.fs.Open(filepath.Dir(l.path)); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	if err := l.open(); err != nil {
+		return err
+	}
+	if l.info, err = l.file.Stat(); err != nil {
+		return err
+	}
+	return l.replay()
+}
+
+// ids returns the ids of the live resources, sorted by their offsets in the log file.
+func (l *LogStoreClient) ids() []string {
+	ids := make([]string, 0, len(l.index))
+	for id := range l.index {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return l.index[ids[i]].offset < l.index[ids[j]].offset })
+	return ids
+}
+
+// read reads the resource from the log file, given the lock is held.
+func (l *LogStoreClient) read(id string) ([]byte, string, error) {
+	entry, ok := l.index[id]
+	if !ok {
+		return nil, "", ErrNotFound
+	}
+	b := make([]byte, entry.contentLength)
+	if _, err := l.file.ReadAt(b, entry.contentOffset); err != nil {
+		return nil, "", err
+	}
+	return b, contentVersion(b), nil
+}
+
+// checkVersion returns ErrNotFound if the resource doesn't exist, or ErrConflict if version is not empty and doesn't
+// match the current version of the resource.
+func (l *LogStoreClient) checkVersion(id string, version string) error {
+	_, current, err := l.read(id)
+	if err != nil {
+		return err
+	}
+	if version != "" && version != current {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (l *LogStoreClient) Create(ctx context.Context, b []byte) (string, error) {
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return "", err
+	}
+	unlock, err := l.lock(ctx, true)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	if _, ok := l.index[id]; ok {
+		return "", fmt.Errorf("resource %s already exists", id)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return id, l.append(logOpPut, id, b)
+}
+
+func (l *LogStoreClient) Read(ctx context.Context, id string) ([]byte, string, error) {
+	unlock, err := l.lock(ctx, false)
+	if err != nil {
+		return nil, "", err
+	}
+	defer unlock()
+	return l.read(id)
+}
+
+func (l *LogStoreClient) Update(ctx context.Context, id string, b []byte, version string) error {
+	unlock, err := l.lock(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := l.checkVersion(id, version); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return l.append(logOpPut, id, b)
+}
+
+func (l *LogStoreClient) Patch(ctx context.Context, id string, patch []byte, version string) error {
+	unlock, err := l.lock(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	b, current, err := l.read(id)
+	if err != nil {
+		return err
+	}
+	if version != "" && version != current {
+		return ErrConflict
+	}
+	b, err = MergePatch(b, patch)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return l.append(logOpPut, id, b)
+}
+
+func (l *LogStoreClient) Delete(ctx context.Context, id string, version string) error {
+	unlock, err := l.lock(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := l.checkVersion(id, version); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return l.append(logOpDelete, id, nil)
+}
+
+func (l *LogStoreClient) List(ctx context.Context, withContent bool) ([]Object, error) {
+	unlock, err := l.lock(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	var objects []Object
+	for _, id := range l.ids() {
+		obj := Object{ID: id}
+		if withContent {
+			b, version, err := l.read(id)
+			if err != nil {
+				return nil, err
+			}
+			obj.Content, obj.Version = b, version
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// Close closes the log file.
+func (l *LogStoreClient) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
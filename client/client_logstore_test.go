@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogStoreClient(t *testing.T) {
+	ctx := context.Background()
+	c, err := newLogStoreClient(afero.NewMemMapFs(), "/tmp/demo.log", nil)
+	require.NoError(t, err)
+	content := []byte(`{"name": "foo"}`)
+	id, err := c.Create(ctx, content)
+	require.NoError(t, err, "create failed")
+	got, _, err := c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.Equal(t, content, got, "read after creation")
+	content = []byte(`{"name": "bar"}`)
+	require.NoError(t, c.Update(ctx, id, content, ""), "update failed")
+	got, _, err = c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.Equal(t, content, got, "read after update")
+	require.NoError(t, c.Delete(ctx, id, ""), "delete failed")
+	_, _, err = c.Read(ctx, id)
+	require.Equal(t, ErrNotFound, err, "read non existent resource should return ErrNotFound")
+	require.Equal(t, ErrNotFound, c.Update(ctx, id, content, ""), "update non existent resource")
+	require.Equal(t, ErrNotFound, c.Delete(ctx, id, ""), "delete non existent resource")
+}
+
+func TestLogStoreClientVersion(t *testing.T) {
+	ctx := context.Background()
+	c, err := newLogStoreClient(afero.NewMemMapFs(), "/tmp/demo.log", nil)
+	require.NoError(t, err)
+	id, err := c.Create(ctx, []byte(`{"name": "foo", "age": 1}`))
+	require.NoError(t, err, "create failed")
+	_, version, err := c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+
+	require.NoError(t, c.Patch(ctx, id, []byte(`{"name": "bar", "age": null}`), version), "patch failed")
+	got, _, err := c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.JSONEq(t, `{"name": "bar"}`, string(got), "read after patch")
+	require.Equal(t, ErrConflict, c.Patch(ctx, id, []byte(`{"name": "baz"}`), version), "patch with stale version")
+	require.Equal(t, ErrConflict, c.Update(ctx, id, []byte(`{"name": "baz"}`), version), "update with stale version")
+	require.Equal(t, ErrConflict, c.Delete(ctx, id, version), "delete with stale version")
+
+	_, version, err = c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.NoError(t, c.Delete(ctx, id, version), "delete with current version")
+}
+
+func TestLogStoreClientReopen(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	c, err := newLogStoreClient(fs, "/tmp/demo.log", nil)
+	require.NoError(t, err)
+	id1, err := c.Create(ctx, []byte(`{"name": "foo"}`))
+	require.NoError(t, err, "create failed")
+	id2, err := c.Create(ctx, []byte(`{"name": "bar"}`))
+	require.NoError(t, err, "create failed")
+	require.NoError(t, c.Update(ctx, id1, []byte(`{"name": "baz"}`), ""), "update failed")
+	require.NoError(t, c.Delete(ctx, id2, ""), "delete failed")
+	require.NoError(t, c.Close())
+
+	b, err := afero.ReadFile(fs, "/tmp/demo.log")
+	require.NoError(t, err)
+	// Simulate a crash in the middle of appending a record.
+	torn := encodeLogRecord(logOpPut, "torn", []byte(`{"name": "torn"}`))
+	require.NoError(t, afero.WriteFile(fs, "/tmp/demo.log", append(b, torn[:len(torn)-3]...), 0644))
+
+	c, err = newLogStoreClient(fs, "/tmp/demo.log", nil)
+	require.NoError(t, err, "reopen failed")
+	objs, err := c.List(ctx, true)
+	require.NoError(t, err, "list failed")
+	require.Equal(t, []Object{{ID: id1, Content: []byte(`{"name": "baz"}`), Version: contentVersion([]byte(`{"name": "baz"}`))}}, objs, "the index is rebuilt from the log")
+	got, err := afero.ReadFile(fs, "/tmp/demo.log")
+	require.NoError(t, err)
+	require.Equal(t, b, got, "the partially written record is truncated")
+
+	// A corrupted record in the middle is not silently dropped.
+	corrupted := append([]byte{}, b...)
+	corrupted[len(logStoreMagic)+logRecordHeaderSize] ^= 1
+	require.NoError(t, afero.WriteFile(fs, "/tmp/corrupted.log", corrupted, 0644))
+	_, err = newLogStoreClient(fs, "/tmp/corrupted.log", nil)
+	require.ErrorContains(t, err, "corrupted record")
+
+	require.NoError(t, afero.WriteFile(fs, "/tmp/other.log", []byte(`{"name": "foo"}`), 0644))
+	_, err = newLogStoreClient(fs, "/tmp/other.log", nil)
+	require.ErrorContains(t, err, "not a log store file")
+}
+
+func TestLogStoreClientCompact(t *testing.T) {
+	ctx := context.Background()
+	fs := afero.NewMemMapFs()
+	c, err := newLogStoreClient(fs, "/tmp/demo.log", &LogStoreClientOption{CompactionRatio: -1})
+	require.NoError(t, err)
+	id1, err := c.Create(ctx, []byte(`{"count": 0}`))
+	require.NoError(t, err, "create failed")
+	id2, err := c.Create(ctx, []byte(`{"name": "deleted"}`))
+	require.NoError(t, err, "create failed")
+	require.NoError(t, c.Delete(ctx, id2, ""), "delete failed")
+	for i := 0; i < 100; i++ {
+		require.NoError(t, increment(ctx, c, id1), "increment failed")
+	}
+	before, err := fs.Stat("/tmp/demo.log")
+	require.NoError(t, err)
+
+	require.NoError(t, c.Compact(ctx), "compact failed")
+	after, err := fs.Stat("/tmp/demo.log")
+	require.NoError(t, err)
+	require.Equal(t, int64(len(logStoreMagic)+len(encodeLogRecord(logOpPut, id1, []byte(`{"count":100}`)))), after.Size(), "only the live record is kept")
+	require.Less(t, after.Size(), before.Size())
+	objs, err := c.List(ctx, true)
+	require.NoError(t, err, "list failed")
+	require.Equal(t, []Object{{ID: id1, Content: []byte(`{"count":100}`), Version: contentVersion([]byte(`{"count":100}`))}}, objs, "list after compaction")
+	entries, err := afero.ReadDir(fs, "/tmp")
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "no temporary file is left")
+
+	// The automatic compaction.
+	c, err = newLogStoreClient(fs, "/tmp/auto.log", &LogStoreClientOption{CompactionMinSize: 512})
+	require.NoError(t, err)
+	id, err := c.Create(ctx, []byte(`{"count": 0}`))
+	require.NoError(t, err, "create failed")
+	for i := 0; i < 100; i++ {
+		require.NoError(t, increment(ctx, c, id), "increment failed")
+	}
+	info, err := fs.Stat("/tmp/auto.log")
+	require.NoError(t, err)
+	require.Less(t, info.Size(), int64(1024), "the log file is compacted automatically")
+	b, _, err := c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.JSONEq(t, `{"count": 100}`, string(b))
+
+	// The compaction on open, of the log file written with the automatic compaction disabled.
+	c, err = newLogStoreClient(fs, "/tmp/open.log", &LogStoreClientOption{CompactionRatio: -1})
+	require.NoError(t, err)
+	id, err = c.Create(ctx, []byte(`{"count": 0}`))
+	require.NoError(t, err, "create failed")
+	for i := 0; i < 100; i++ {
+		require.NoError(t, increment(ctx, c, id), "increment failed")
+	}
+	info, err = fs.Stat("/tmp/open.log")
+	require.NoError(t, err)
+	require.Greater(t, info.Size(), int64(1024))
+	c, err = newLogStoreClient(fs, "/tmp/open.log", &LogStoreClientOption{CompactionMinSize: 512})
+	require.NoError(t, err)
+	info, err = fs.Stat("/tmp/open.log")
+	require.NoError(t, err)
+	require.Less(t, info.Size(), int64(1024), "the log file is compacted on open")
+	b, _, err = c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.JSONEq(t, `{"count": 100}`, string(b))
+}
+
+func TestLogStoreClientConcurrent(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "demo.log")
+	// Multiple clients on the same log file, as if they were in different processes.
+	var clients []*LogStoreClient
+	for i := 0; i < 3; i++ {
+		c, err := newLogStoreClient(afero.NewOsFs(), path, &LogStoreClientOption{CompactionMinSize: 1024})
+		require.NoError(t, err)
+		clients = append(clients, c)
+	}
+	id, err := clients[0].Create(ctx, []byte(`{"count": 0}`))
+	require.NoError(t, err, "create failed")
+
+	errCh := make(chan error, len(clients))
+	for _, c := range clients {
+		c := c
+		go func() {
+			errCh <- hammer(ctx, c, id, 5, 20)
+		}()
+	}
+	for range clients {
+		require.NoError(t, <-errCh)
+	}
+	for _, c := range clients {
+		b, _, err := c.Read(ctx, id)
+		require.NoError(t, err, "read failed")
+		require.JSONEq(t, `{"count": 300}`, string(b), "no update is lost")
+	}
+}
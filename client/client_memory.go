@@ -0,0 +1,186 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/hashicorp/go-uuid"
+)
+
+// MemoryClient keeps the resources in memory, which is mainly for testing. It is safe for concurrent use.
+type MemoryClient struct {
+	mu      sync.RWMutex
+	objects map[string][]byte
+}
+
+var _ Client = &MemoryClient{}
+
+func NewMemoryClient() *MemoryClient {
+	return &MemoryClient{objects: map[string][]byte{}}
+}
+
+var (
+	sharedMemoryClientsMu sync.Mutex
+	sharedMemoryClients   = map[string]*MemoryClient{}
+)
+
+// SharedMemoryClient returns the MemoryClient of the name, which is created on the first call. It allows the
+// provider and the tests running in the same process to share the same store.
+func SharedMemoryClient(name string) *MemoryClient {
+	sharedMemoryClientsMu.Lock()
+	defer sharedMemoryClientsMu.Unlock()
+	c, ok := sharedMemoryClients[name]
+	if !ok {
+		c = NewMemoryClient()
+		sharedMemoryClients[name] = c
+	}
+	return c
+}
+
+func (m *MemoryClient) Create(ctx context.Context, b []byte) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.objects[id]; ok {
+		return "", fmt.Errorf("resource %s already exists", id)
+	}
+	m.objects[id] = copyBytes(b)
+	return id, nil
+}
+
+func (m *MemoryClient) Read(ctx context.Context, id string) ([]byte, string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, ok := m.objects[id]
+	if !ok {
+		return nil, "", ErrNotFound
+	}
+	return copyBytes(b), contentVersion(b), nil
+}
+
+func (m *MemoryClient) Update(ctx context.Context, id string, b []byte, version string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.checkVersion(id, version); err != nil {
+		return err
+	}
+	m.objects[id] = copyBytes(b)
+	return nil
+}
+
+func (m *MemoryClient) Patch(ctx context.Context, id string, patch []byte, version string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.checkVersion(id, version); err != nil {
+		return err
+	}
+	b, err := MergePatch(m.objects[id], patch)
+	if err != nil {
+		return err
+	}
+	m.objects[id] = b
+	return nil
+}
+
+func (m *MemoryClient) Delete(ctx context.Context, id string, version string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.checkVersion(id, version); err != nil {
+		return err
+	}
+	delete(m.objects, id)
+	return nil
+}
+
+func (m *MemoryClient) List(ctx context.Context, withContent bool) ([]Object, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	objects := make([]Object, 0, len(m.objects))
+	for id, b := range m.objects {
+		obj := Object{ID: id}
+		if withContent {
+			obj.Content = copyBytes(b)
+			obj.Version = contentVersion(b)
+		}
+		objects = append(objects, obj)
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].ID < objects[j].ID })
+	return objects, nil
+}
+
+// checkVersion returns ErrNotFound if the resource doesn't exist, or ErrConflict if version is not empty and doesn't
+// match the current version of the resource. The lock is expected to be held.
+func (m *MemoryClient) checkVersion(id string, version string) error {
+	b, ok := m.objects[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if version != "" && version != contentVersion(b) {
+		return ErrConflict
+	}
+	return nil
+}
+
+// Snapshot returns a copy of all the resources by their ids.
+func (m *MemoryClient) Snapshot() map[string][]byte {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	snapshot := make(map[string][]byte, len(m.objects))
+	for id, b := range m.objects {
+		snapshot[id] = copyBytes(b)
+	}
+	return snapshot
+}
+
+// Load replaces all the resources with the snapshot.
+func (m *MemoryClient) Load(snapshot map[string][]byte) {
+	objects := make(map[string][]byte, len(snapshot))
+	for id, b := range snapshot {
+		objects[id] = copyBytes(b)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects = objects
+}
+
+// Len returns the number of the resources.
+func (m *MemoryClient) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.objects)
+}
+
+// Reset removes all the resources.
+func (m *MemoryClient) Reset() {
+	m.Load(nil)
+}
+
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
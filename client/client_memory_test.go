@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryClient(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryClient()
+	content := []byte(`{"name": "foo"}`)
+	id, err := c.Create(ctx, content)
+	require.NoError(t, err, "create failed")
+	got, version, err := c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.Equal(t, content, got, "read after creation")
+
+	require.NoError(t, c.Patch(ctx, id, []byte(`{"age": 1}`), version), "patch failed")
+	got, _, err = c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.JSONEq(t, `{"name": "foo", "age": 1}`, string(got), "read after patch")
+	require.Equal(t, ErrConflict, c.Update(ctx, id, []byte(`{"name": "bar"}`), version), "update with stale version")
+	require.Equal(t, ErrConflict, c.Patch(ctx, id, []byte(`{"name": "bar"}`), version), "patch with stale version")
+	require.Equal(t, ErrConflict, c.Delete(ctx, id, version), "delete with stale version")
+	require.NoError(t, c.Update(ctx, id, []byte(`{"name": "bar"}`), ""), "update failed")
+
+	objs, err := c.List(ctx, true)
+	require.NoError(t, err, "list failed")
+	require.Equal(t, []Object{{ID: id, Content: []byte(`{"name": "bar"}`), Version: contentVersion([]byte(`{"name": "bar"}`))}}, objs, "list with content")
+
+	require.NoError(t, c.Delete(ctx, id, ""), "delete failed")
+	_, _, err = c.Read(ctx, id)
+	require.Equal(t, ErrNotFound, err, "read non existent resource should return ErrNotFound")
+	require.Equal(t, ErrNotFound, c.Update(ctx, id, content, ""), "update non existent resource")
+	require.Equal(t, ErrNotFound, c.Patch(ctx, id, content, ""), "patch non existent resource")
+	require.Equal(t, ErrNotFound, c.Delete(ctx, id, ""), "delete non existent resource")
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = c.Create(ctx, content)
+	require.ErrorIs(t, err, context.Canceled, "create with canceled context")
+}
+
+func TestMemoryClientSnapshot(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryClient()
+	content := []byte(`{"name": "foo"}`)
+	id, err := c.Create(ctx, content)
+	require.NoError(t, err, "create failed")
+	content[0] = 'x'
+	require.Equal(t, map[string][]byte{id: []byte(`{"name": "foo"}`)}, c.Snapshot(), "the content is copied")
+
+	snapshot := c.Snapshot()
+	snapshot[id][0] = 'x'
+	require.NoError(t, c.Update(ctx, id, []byte(`{"name": "bar"}`), ""), "update failed")
+	require.Equal(t, 1, c.Len())
+
+	c.Load(map[string][]byte{"a": []byte(`{}`), "b": []byte(`{}`)})
+	require.Equal(t, 2, c.Len())
+	_, _, err = c.Read(ctx, id)
+	require.Equal(t, ErrNotFound, err, "the resources are replaced by the snapshot")
+	c.Reset()
+	require.Equal(t, 0, c.Len())
+}
+
+func TestSharedMemoryClient(t *testing.T) {
+	require.Same(t, SharedMemoryClient("TestSharedMemoryClient"), SharedMemoryClient("TestSharedMemoryClient"))
+	require.NotSame(t, SharedMemoryClient("TestSharedMemoryClient"), SharedMemoryClient("TestSharedMemoryClient2"))
+}
+
+func TestMemoryClientConcurrent(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryClient()
+	id, err := c.Create(ctx, []byte(`{"count": 0}`))
+	require.NoError(t, err, "create failed")
+	require.NoError(t, hammer(ctx, c, id, 20, 20))
+	b, _, err := c.Read(ctx, id)
+	require.NoError(t, err, "read failed")
+	require.JSONEq(t, `{"count": 400}`, string(b), "no update is lost")
+}
@@ -0,0 +1,363 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// RESTRoute defines how an operation is mapped to the HTTP request.
+type RESTRoute struct {
+	// Method is the HTTP method of the request.
+	Method string
+	// Path is the path template relative to the base URL, where "{id}" is replaced by the escaped id of the resource.
+	// It can carry a query string, e.g. "{id}?expand=true".
+	Path string
+	// StatusCodes are the status codes of the successful responses.
+	StatusCodes []int
+}
+
+type RESTClient struct {
+	baseURL url.URL
+	*httpSender
+
+	create         RESTRoute
+	read           RESTRoute
+	update         RESTRoute
+	patch          *RESTRoute
+	delete         RESTRoute
+	list           RESTRoute
+	idPath         string
+	idFromLocation bool
+	listItemsPath  string
+	listItemIDPath string
+}
+
+type RESTClientOption struct {
+	// Retry configures how the failed requests are retried. Nil means no retry.
+	Retry *RetryOption
+	// Transport configures the HTTP client. Nil means using the http.DefaultClient.
+	Transport *TransportOption
+	// Auth configures how the requests are authenticated. Nil means no authentication.
+	Auth *AuthOption
+	// Compress makes the request bodies compressed by gzip, and asks for the gzip compressed responses.
+	Compress bool
+	// WireLog configures the redaction of the requests and the responses logged to the wire log subsystem. Nil means
+	// the default redaction.
+	WireLog *WireLogOption
+
+	// Create is the route creating a resource. Defaults to "POST" to the base URL, expecting 200 or 201.
+	Create *RESTRoute
+	// Read is the route reading a resource. Defaults to "GET" on "{id}", expecting 200.
+	Read *RESTRoute
+	// Update is the route replacing a resource. Defaults to "PUT" on "{id}", expecting 200 or 204.
+	Update *RESTRoute
+	// Patch is the route sending the JSON merge patch to a resource. Nil means the patch is applied to the read
+	// resource locally, then sent by the Update route.
+	Patch *RESTRoute
+	// Delete is the route deleting a resource. Defaults to "DELETE" on "{id}", expecting 200 or 204.
+	Delete *RESTRoute
+	// List is the route listing the resources. Defaults to "GET" on the base URL, expecting 200.
+	List *RESTRoute
+
+	// IDPath is the JSON path of the id in the response of the Create route. Defaults to "$.id".
+	IDPath string
+	// IDFromLocation makes the id taken from the last path segment of the "Location" header of the response of the
+	// Create route, instead of from its body.
+	IDFromLocation bool
+	// ListItemsPath is the JSON path of the array of the resources in the response of the List route. Defaults to "$".
+	ListItemsPath string
+	// ListItemIDPath is the JSON path of the id in each listed resource. Defaults to "$.id".
+	ListItemIDPath string
+}
+
+func NewRESTClient(endpoint string, opt *RESTClientOption) (Client, error) {
+	baseURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if opt == nil {
+		opt = &RESTClientOption{}
+	}
+	sender, err := newHTTPSender(opt.Transport, opt.Retry, opt.Auth, opt.Compress, opt.WireLog)
+	if err != nil {
+		return nil, err
+	}
+	c := &RESTClient{
+		baseURL:        *baseURL,
+		httpSender:     sender,
+		create:         restRoute(opt.Create, RESTRoute{"POST", "", []int{http.StatusOK, http.StatusCreated}}),
+		read:           restRoute(opt.Read, RESTRoute{"GET", "{id}", []int{http.StatusOK}}),
+		update:         restRoute(opt.Update, RESTRoute{"PUT", "{id}", []int{http.StatusOK, http.StatusNoContent}}),
+		delete:         restRoute(opt.Delete, RESTRoute{"DELETE", "{id}", []int{http.StatusOK, http.StatusNoContent}}),
+		list:           restRoute(opt.List, RESTRoute{"GET", "", []int{http.StatusOK}}),
+		idPath:         opt.IDPath,
+		idFromLocation: opt.IDFromLocation,
+		listItemsPath:  opt.ListItemsPath,
+		listItemIDPath: opt.ListItemIDPath,
+	}
+	if opt.Patch != nil {
+		patch := restRoute(opt.Patch, RESTRoute{"PATCH", "{id}", []int{http.StatusOK, http.StatusNoContent}})
+		c.patch = &patch
+	}
+	if c.idPath == "" {
+		c.idPath = "$.id"
+	}
+	if c.listItemsPath == "" {
+		c.listItemsPath = "$"
+	}
+	if c.listItemIDPath == "" {
+		c.listItemIDPath = "$.id"
+	}
+	for _, p := range []string{c.idPath, c.listItemsPath, c.listItemIDPath} {
+		if _, err := parseJSONPath(p); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// restRoute returns the route with the unset method and status codes filled by the default route. The path is always
+// taken from the route, as the empty path means the base URL.
+func restRoute(route *RESTRoute, def RESTRoute) RESTRoute {
+	if route == nil {
+		return def
+	}
+	out := *route
+	if out.Method == "" {
+		out.Method = def.Method
+	}
+	if len(out.StatusCodes) == 0 {
+		out.StatusCodes = def.StatusCodes
+	}
+	return out
+}
+
+func (r *RESTClient) Create(ctx context.Context, b []byte) (string, error) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	resp, err := r.send(ctx, r.create, "", header, b)
+	if err != nil {
+		return "", fmt.Errorf("create: %w", err)
+	}
+	if r.idFromLocation {
+		location := resp.Header.Get("Location")
+		if location == "" {
+			return "", fmt.Errorf(`no "Location" header in the create response`)
+		}
+		u, err := url.Parse(location)
+		if err != nil {
+			return "", fmt.Errorf("parsing location %q: %v", location, err)
+		}
+		id := path.Base(strings.TrimSuffix(u.Path, "/"))
+		if id == "." || id == "/" {
+			return "", fmt.Errorf("no id in the location %q", location)
+		}
+		return id, nil
+	}
+	return lookupID(resp.Body, r.idPath)
+}
+
+func (r *RESTClient) Read(ctx context.Context, id string) ([]byte, string, error) {
+	resp, err := r.send(ctx, r.read, id, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	version := resp.Header.Get("ETag")
+	if version == "" {
+		version = contentVersion(resp.Body)
+	}
+	return resp.Body, version, nil
+}
+
+func (r *RESTClient) Update(ctx context.Context, id string, b []byte, version string) error {
+	ifMatch, err := r.checkVersion(ctx, id, version)
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	if ifMatch != "" {
+		header.Set("If-Match", ifMatch)
+	}
+	_, err = r.send(ctx, r.update, id, header, b)
+	return err
+}
+
+func (r *RESTClient) Patch(ctx context.Context, id string, patch []byte, version string) error {
+	if r.patch == nil {
+		b, current, err := r.Read(ctx, id)
+		if err != nil {
+			return err
+		}
+		if version != "" && version != current {
+			return ErrConflict
+		}
+		if b, err = MergePatch(b, patch); err != nil {
+			return err
+		}
+		return r.Update(ctx, id, b, current)
+	}
+	ifMatch, err := r.checkVersion(ctx, id, version)
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/merge-patch+json")
+	if ifMatch != "" {
+		header.Set("If-Match", ifMatch)
+	}
+	_, err = r.send(ctx, *r.patch, id, header, patch)
+	return err
+}
+
+func (r *RESTClient) Delete(ctx context.Context, id string, version string) error {
+	ifMatch, err := r.checkVersion(ctx, id, version)
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	if ifMatch != "" {
+		header.Set("If-Match", ifMatch)
+	}
+	_, err = r.send(ctx, r.delete, id, header, nil)
+	return err
+}
+
+func (r *RESTClient) List(ctx context.Context, withContent bool) ([]Object, error) {
+	resp, err := r.send(ctx, r.list, "", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decodeJSON(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	v, err := jsonPathLookup(doc, r.listItemsPath)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("the list items at %q is not an array", r.listItemsPath)
+	}
+	var objects []Object
+	for _, item := range items {
+		v, err := jsonPathLookup(item, r.listItemIDPath)
+		if err != nil {
+			return nil, err
+		}
+		id, err := idString(v)
+		if err != nil {
+			return nil, err
+		}
+		obj := Object{ID: id}
+		if withContent {
+			if obj.Content, err = json.Marshal(item); err != nil {
+				return nil, err
+			}
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// checkVersion returns ErrConflict if version is not empty and doesn't match the current version of the resource.
+// The version is either the ETag of the resource, which is then returned to be sent as the "If-Match" header, or the
+// hash of the content, for the servers not supporting the ETag.
+func (r *RESTClient) checkVersion(ctx context.Context, id string, version string) (string, error) {
+	if version == "" {
+		return "", nil
+	}
+	resp, err := r.send(ctx, r.read, id, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		if etag != version {
+			return "", ErrConflict
+		}
+		return etag, nil
+	}
+	if contentVersion(resp.Body) != version {
+		return "", ErrConflict
+	}
+	return "", nil
+}
+
+// send sends the request of the route for the resource, and checks the status code of the response.
+func (r *RESTClient) send(ctx context.Context, route RESTRoute, id string, header http.Header, body []byte) (*response, error) {
+	u, err := r.routeURL(route, id)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.do(ctx, route.Method, u, header, body)
+	if err != nil {
+		return nil, err
+	}
+	if statuscodeMatches(resp.StatusCode, route.StatusCodes...) {
+		return resp, nil
+	}
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		return nil, ErrConflict
+	}
+	return nil, r.statusError(resp)
+}
+
+// routeURL returns the URL of the route for the resource, by resolving the path template against the base URL.
+func (r *RESTClient) routeURL(route RESTRoute, id string) (url.URL, error) {
+	ref, err := url.Parse(strings.ReplaceAll(route.Path, "{id}", url.PathEscape(id)))
+	if err != nil {
+		return url.URL{}, fmt.Errorf("parsing the route path %q: %v", route.Path, err)
+	}
+	u := r.baseURL
+	if ref.Path != "" {
+		// Join the escaped paths, so that the escaped "/" in the id is kept.
+		escaped := path.Join(u.EscapedPath(), ref.EscapedPath())
+		p, err := url.PathUnescape(escaped)
+		if err != nil {
+			return url.URL{}, err
+		}
+		u.Path, u.RawPath = p, escaped
+	}
+	if ref.RawQuery != "" {
+		q := u.Query()
+		for k, v := range ref.Query() {
+			q[k] = v
+		}
+		u.RawQuery = q.Encode()
+	}
+	return u, nil
+}
+
+// decodeJSON decodes the JSON document, keeping the numbers as json.Number.
+func decodeJSON(b []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// lookupID returns the id at the JSON path of the JSON document.
+func lookupID(b []byte, idPath string) (string, error) {
+	doc, err := decodeJSON(b)
+	if err != nil {
+		return "", err
+	}
+	v, err := jsonPathLookup(doc, idPath)
+	if err != nil {
+		return "", err
+	}
+	return idString(v)
+}
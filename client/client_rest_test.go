@@ -0,0 +1,248 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// restHandler mimics a small CRUD API under "/api/items", which wraps the created resource and the listed resources
+// in an envelope, and uses string ids.
+type restHandler struct {
+	mu   sync.Mutex
+	i    int
+	objs map[string][]byte
+}
+
+func newRESTHandler() *restHandler {
+	return &restHandler{objs: map[string][]byte{}}
+}
+
+func (h *restHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	const prefix = "/api/items"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		b, _ := io.ReadAll(r.Body)
+		if !json.Valid(b) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		h.i++
+		id := fmt.Sprintf("item-%d", h.i)
+		h.objs[id] = b
+		w.Header().Set("Location", prefix+"/"+id)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"data": {"id": %q}}`, id)
+	case id == "" && r.Method == http.MethodGet:
+		var ids []string
+		for id := range h.objs {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		var items []json.RawMessage
+		for _, id := range ids {
+			items = append(items, json.RawMessage(fmt.Sprintf(`{"key": %q, "value": %s}`, id, h.objs[id])))
+		}
+		b, _ := json.Marshal(map[string]interface{}{"items": items})
+		w.Write(b)
+	case id == "":
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	default:
+		b, ok := h.objs[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			w.Write(b)
+		case http.MethodPut:
+			b, _ := io.ReadAll(r.Body)
+			h.objs[id] = b
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			delete(h.objs, id)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func TestRESTClient(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(newRESTHandler())
+	defer srv.Close()
+
+	for _, opt := range []*RESTClientOption{
+		{IDPath: "$.data.id", ListItemsPath: "$.items", ListItemIDPath: "$.key"},
+		{IDFromLocation: true, ListItemsPath: "$['items']", ListItemIDPath: "$['key']"},
+	} {
+		c, err := NewRESTClient(srv.URL+"/api/items", opt)
+		require.NoError(t, err)
+
+		id, err := c.Create(ctx, []byte(`{"a": 1}`))
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(id, "item-"))
+
+		b, version, err := c.Read(ctx, id)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"a": 1}`, string(b))
+		require.Equal(t, contentVersion(b), version)
+
+		require.NoError(t, c.Update(ctx, id, []byte(`{"a": 2}`), version))
+		require.ErrorIs(t, c.Update(ctx, id, []byte(`{"a": 3}`), version), ErrConflict)
+
+		b, version, err = c.Read(ctx, id)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"a": 2}`, string(b))
+
+		// The patch is emulated by the read and the update.
+		require.NoError(t, c.Patch(ctx, id, []byte(`{"b": 1}`), version))
+		require.ErrorIs(t, c.Patch(ctx, id, []byte(`{"b": 2}`), version), ErrConflict)
+		b, _, err = c.Read(ctx, id)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"a": 2, "b": 1}`, string(b))
+
+		objs, err := c.List(ctx, true)
+		require.NoError(t, err)
+		require.Len(t, objs, 1)
+		require.Equal(t, id, objs[0].ID)
+		require.JSONEq(t, fmt.Sprintf(`{"key": %q, "value": {"a": 2, "b": 1}}`, id), string(objs[0].Content))
+
+		require.NoError(t, c.Delete(ctx, id, ""))
+		_, _, err = c.Read(ctx, id)
+		require.ErrorIs(t, err, ErrNotFound)
+		require.ErrorIs(t, c.Delete(ctx, id, ""), ErrNotFound)
+	}
+}
+
+func TestRESTClientRoutes(t *testing.T) {
+	ctx := context.Background()
+	var (
+		mu   sync.Mutex
+		reqs []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		reqs = append(reqs, r.Method+" "+r.URL.RequestURI())
+		mu.Unlock()
+		switch r.Method {
+		case http.MethodPost:
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"result": [{"name": 42}]}`))
+		case http.MethodGet:
+			w.Write([]byte(`{"a": 1}`))
+		case "PATCH":
+			require.Equal(t, "application/merge-patch+json", r.Header.Get("Content-Type"))
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewRESTClient(srv.URL+"/v1?tenant=t1", &RESTClientOption{
+		Create: &RESTRoute{Path: "things/new", StatusCodes: []int{http.StatusAccepted}},
+		Read:   &RESTRoute{Path: "things/{id}?expand=true"},
+		Update: &RESTRoute{Method: "POST", Path: "things/{id}/replace", StatusCodes: []int{http.StatusAccepted}},
+		Patch:  &RESTRoute{Path: "things/{id}", StatusCodes: []int{http.StatusAccepted}},
+		Delete: &RESTRoute{Method: "POST", Path: "things/{id}/delete"},
+		IDPath: "$.result[0].name",
+	})
+	require.NoError(t, err)
+
+	id, err := c.Create(ctx, []byte(`{}`))
+	require.NoError(t, err)
+	require.Equal(t, "42", id)
+	_, _, err = c.Read(ctx, "a/b")
+	require.NoError(t, err)
+	require.NoError(t, c.Update(ctx, id, []byte(`{}`), ""))
+	require.NoError(t, c.Patch(ctx, id, []byte(`{}`), ""))
+	// The delete route is expecting the default 200 or 204, while the server responds 202 to any POST.
+	require.Error(t, c.Delete(ctx, id, ""))
+
+	require.Equal(t, []string{
+		"POST /v1/things/new?tenant=t1",
+		"GET /v1/things/a%2Fb?expand=true&tenant=t1",
+		"POST /v1/things/42/replace?tenant=t1",
+		"PATCH /v1/things/42?tenant=t1",
+		"POST /v1/things/42/delete?tenant=t1",
+	}, reqs)
+}
+
+func TestRESTClientStatusCode(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/conflict":
+			w.WriteHeader(http.StatusConflict)
+		case "/precondition":
+			w.WriteHeader(http.StatusPreconditionFailed)
+		case "/notfound":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusTeapot)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewRESTClient(srv.URL, nil)
+	require.NoError(t, err)
+	require.ErrorIs(t, c.Update(ctx, "conflict", []byte(`{}`), ""), ErrConflict)
+	require.ErrorIs(t, c.Update(ctx, "precondition", []byte(`{}`), ""), ErrConflict)
+	require.ErrorIs(t, c.Delete(ctx, "notfound", ""), ErrNotFound)
+	err = c.Delete(ctx, "other", "")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusTeapot, apiErr.StatusCode)
+
+	_, err = NewRESTClient(srv.URL, &RESTClientOption{IDPath: "id"})
+	require.Error(t, err)
+}
+
+func TestJSONPathLookup(t *testing.T) {
+	var doc interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"a": {"b c": [1, {"d": "x"}]}}`), &doc))
+	cases := []struct {
+		path   string
+		expect interface{}
+		err    bool
+	}{
+		{path: "$", expect: doc},
+		{path: "$.a['b c'][0]", expect: float64(1)},
+		{path: "$.a['b c'][1].d", expect: "x"},
+		{path: "$.a['b c'][2]", err: true},
+		{path: "$.x", err: true},
+		{path: "$.a[0]", err: true},
+		{path: "a", err: true},
+		{path: "$..a", err: true},
+		{path: "$[x]", err: true},
+	}
+	for _, tt := range cases {
+		v, err := jsonPathLookup(doc, tt.path)
+		if tt.err {
+			require.Error(t, err, tt.path)
+			continue
+		}
+		require.NoError(t, err, tt.path)
+		require.Equal(t, tt.expect, v, tt.path)
+	}
+}
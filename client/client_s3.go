@@ -0,0 +1,325 @@
+package client
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+)
+
+// S3Client stores each resource as an object of an S3 compatible bucket, whose key is the prefix followed by the id.
+// The version of a resource is the ETag of its object.
+type S3Client struct {
+	baseURL   url.URL
+	pathStyle bool
+	bucket    string
+	prefix    string
+	// conditionalWrites makes the writes carry the "If-Match" and "If-None-Match" headers, so that the versions are
+	// checked by the server atomically.
+	conditionalWrites bool
+	*httpSender
+}
+
+type S3ClientOption struct {
+	// Endpoint is the URL of the S3 compatible service, e.g. "http://localhost:9000" for MinIO. Defaults to the AWS S3
+	// endpoint of the region.
+	Endpoint string
+	// Region is the region used for the signing. Defaults to "us-east-1".
+	Region string
+	// Prefix is the prefix of the object keys, e.g. "demo/". Defaults to no prefix.
+	Prefix string
+	// PathStyle makes the bucket addressed in the path of the URL, instead of in the host name.
+	PathStyle bool
+
+	// AccessKeyID is the access key ID used for the signing. Empty means the requests are sent anonymously.
+	AccessKeyID string
+	// SecretAccessKey is the secret access key used for the signing.
+	SecretAccessKey string
+	// SessionToken is the session token of the temporary credential, if any.
+	SessionToken string
+
+	// ConditionalWrites makes the writes conditional on the versions by the "If-Match" and "If-None-Match" headers,
+	// which are supported by AWS S3 and some of the S3 compatible services. Otherwise, the version is checked by a
+	// read ahead of the write, which is subject to the race with the other writers.
+	ConditionalWrites bool
+
+	// Retry configures how the failed requests are retried. Nil means no retry.
+	Retry *RetryOption
+	// Transport configures the HTTP client. Nil means using the http.DefaultClient.
+	Transport *TransportOption
+	// WireLog configures the redaction of the requests and the responses logged to the wire log subsystem. Nil means
+	// the default redaction.
+	WireLog *WireLogOption
+}
+
+func NewS3Client(bucket string, opt *S3ClientOption) (Client, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("empty bucket")
+	}
+	if opt == nil {
+		opt = &S3ClientOption{}
+	}
+	region := opt.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := opt.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	baseURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if baseURL.Scheme == "" || baseURL.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q", endpoint)
+	}
+	if (opt.AccessKeyID == "") != (opt.SecretAccessKey == "") {
+		return nil, fmt.Errorf("the access key ID and the secret access key must be specified together")
+	}
+	sender, err := newHTTPSender(opt.Transport, opt.Retry, nil, false, opt.WireLog)
+	if err != nil {
+		return nil, err
+	}
+	if opt.AccessKeyID != "" {
+		signer := &sigV4Signer{
+			accessKeyID:     opt.AccessKeyID,
+			secretAccessKey: opt.SecretAccessKey,
+			sessionToken:    opt.SessionToken,
+			region:          region,
+			service:         "s3",
+			now:             time.Now,
+		}
+		sender.sign = signer.sign
+	}
+	return &S3Client{
+		baseURL:           *baseURL,
+		pathStyle:         opt.PathStyle,
+		bucket:            bucket,
+		prefix:            opt.Prefix,
+		conditionalWrites: opt.ConditionalWrites,
+		httpSender:        sender,
+	}, nil
+}
+
+// bucketURL returns the URL of the bucket, which is addressed either in the path or in the host name.
+func (s *S3Client) bucketURL() url.URL {
+	u := s.baseURL
+	if s.pathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket
+	} else {
+		u.Host = s.bucket + "." + u.Host
+	}
+	u.RawPath = ""
+	return u
+}
+
+// objectURL returns the URL of the object of the resource.
+func (s *S3Client) objectURL(id string) url.URL {
+	u := s.bucketURL()
+	key := s.prefix + id
+	var escaped []string
+	for _, seg := range strings.Split(key, "/") {
+		escaped = append(escaped, sigV4Escape(seg))
+	}
+	u.RawPath = strings.TrimSuffix(u.EscapedPath(), "/") + "/" + strings.Join(escaped, "/")
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	return u
+}
+
+func (s *S3Client) Create(ctx context.Context, b []byte) (string, error) {
+	// The generated key (i.e. the UUID) is not expected to be duplicated, which is still checked by the server if the
+	// conditional writes are supported.
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return "", err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	if s.conditionalWrites {
+		header.Set("If-None-Match", "*")
+	}
+	resp, err := s.do(ctx, "PUT", s.objectURL(id), header, b)
+	if err != nil {
+		return "", err
+	}
+	if !statuscodeMatches(resp.StatusCode, http.StatusOK) {
+		return "", s.statusError(resp)
+	}
+	return id, nil
+}
+
+func (s *S3Client) Read(ctx context.Context, id string) ([]byte, string, error) {
+	resp, err := s.do(ctx, "GET", s.objectURL(id), nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if statuscodeMatches(resp.StatusCode, http.StatusNotFound) {
+		return nil, "", ErrNotFound
+	}
+	if !statuscodeMatches(resp.StatusCode, http.StatusOK) {
+		return nil, "", s.statusError(resp)
+	}
+	return resp.Body, resp.Header.Get("ETag"), nil
+}
+
+func (s *S3Client) Update(ctx context.Context, id string, b []byte, version string) error {
+	etag, err := s.head(ctx, id)
+	if err != nil {
+		return err
+	}
+	if version != "" && version != etag {
+		return ErrConflict
+	}
+	return s.put(ctx, id, b, etag)
+}
+
+func (s *S3Client) Patch(ctx context.Context, id string, patch []byte, version string) error {
+	b, etag, err := s.Read(ctx, id)
+	if err != nil {
+		return err
+	}
+	if version != "" && version != etag {
+		return ErrConflict
+	}
+	if b, err = MergePatch(b, patch); err != nil {
+		return err
+	}
+	return s.put(ctx, id, b, etag)
+}
+
+func (s *S3Client) Delete(ctx context.Context, id string, version string) error {
+	etag, err := s.head(ctx, id)
+	if err != nil {
+		return err
+	}
+	if version != "" && version != etag {
+		return ErrConflict
+	}
+	header := http.Header{}
+	if s.conditionalWrites {
+		header.Set("If-Match", etag)
+	}
+	resp, err := s.do(ctx, "DELETE", s.objectURL(id), header, nil)
+	if err != nil {
+		return err
+	}
+	if statuscodeMatches(resp.StatusCode, http.StatusNotFound) {
+		return ErrNotFound
+	}
+	if statuscodeMatches(resp.StatusCode, http.StatusPreconditionFailed) {
+		return ErrConflict
+	}
+	if !statuscodeMatches(resp.StatusCode, http.StatusOK, http.StatusNoContent) {
+		return s.statusError(resp)
+	}
+	return nil
+}
+
+// s3ListBucketResult is the response of the ListObjectsV2.
+type s3ListBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3Client) List(ctx context.Context, withContent bool) ([]Object, error) {
+	var objects []Object
+	var token string
+	for {
+		u := s.bucketURL()
+		if !strings.HasSuffix(u.Path, "/") {
+			u.Path += "/"
+		}
+		q := url.Values{}
+		q.Set("list-type", "2")
+		// The objects in the "subdirectories" of the prefix are not resources.
+		q.Set("delimiter", "/")
+		if s.prefix != "" {
+			q.Set("prefix", s.prefix)
+		}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		u.RawQuery = q.Encode()
+		resp, err := s.do(ctx, "GET", u, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if !statuscodeMatches(resp.StatusCode, http.StatusOK) {
+			return nil, s.statusError(resp)
+		}
+		var result s3ListBucketResult
+		if err := xml.Unmarshal(resp.Body, &result); err != nil {
+			return nil, fmt.Errorf("decoding the list response: %v", err)
+		}
+		for _, content := range result.Contents {
+			id := strings.TrimPrefix(content.Key, s.prefix)
+			if id == "" {
+				continue
+			}
+			obj := Object{ID: id}
+			if withContent {
+				b, version, err := s.Read(ctx, id)
+				if err != nil {
+					// The object is deleted after being listed.
+					if err == ErrNotFound {
+						continue
+					}
+					return nil, err
+				}
+				obj.Content, obj.Version = b, version
+			}
+			objects = append(objects, obj)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// head returns the ETag of the object of the resource.
+func (s *S3Client) head(ctx context.Context, id string) (string, error) {
+	resp, err := s.do(ctx, "HEAD", s.objectURL(id), nil, nil)
+	if err != nil {
+		return "", err
+	}
+	if statuscodeMatches(resp.StatusCode, http.StatusNotFound) {
+		return "", ErrNotFound
+	}
+	if !statuscodeMatches(resp.StatusCode, http.StatusOK) {
+		return "", s.statusError(resp)
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+// put overwrites the object of the resource, whose current ETag is etag.
+func (s *S3Client) put(ctx context.Context, id string, b []byte, etag string) error {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	if s.conditionalWrites {
+		header.Set("If-Match", etag)
+	}
+	resp, err := s.do(ctx, "PUT", s.objectURL(id), header, b)
+	if err != nil {
+		return err
+	}
+	if statuscodeMatches(resp.StatusCode, http.StatusNotFound) {
+		return ErrNotFound
+	}
+	if statuscodeMatches(resp.StatusCode, http.StatusPreconditionFailed) {
+		return ErrConflict
+	}
+	if !statuscodeMatches(resp.StatusCode, http.StatusOK) {
+		return s.statusError(resp)
+	}
+	return nil
+}
@@ -0,0 +1,313 @@
+package client
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testS3AccessKeyID     = "AKIDEXAMPLE"
+	testS3SecretAccessKey = "secret"
+)
+
+// fakeS3 is an in-process S3 compatible server of the path-style addressing, which verifies the SigV4 signatures.
+type fakeS3 struct {
+	t *testing.T
+	// conditional makes the server honor the "If-Match" and "If-None-Match" headers of the writes.
+	conditional bool
+	// maxKeys is the page size of the listing.
+	maxKeys int
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	// headers records the conditional headers of the requests.
+	headers []string
+}
+
+func newFakeS3(t *testing.T, conditional bool) *fakeS3 {
+	return &fakeS3{t: t, conditional: conditional, maxKeys: 2, objects: map[string][]byte{}}
+}
+
+func fakeETag(b []byte) string {
+	return fmt.Sprintf(`"%x"`, md5.Sum(b))
+}
+
+// verify checks the signature of the request, by signing the signed headers of the request again.
+func (f *fakeS3) verify(r *http.Request, body []byte) bool {
+	auth := r.Header.Get("Authorization")
+	_, signed, ok := strings.Cut(auth, "SignedHeaders=")
+	if !ok {
+		return false
+	}
+	signed, _, _ = strings.Cut(signed, ",")
+	date, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+	req, err := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+	if err != nil {
+		return false
+	}
+	for _, k := range strings.Split(signed, ";") {
+		if k != "host" {
+			req.Header[http.CanonicalHeaderKey(k)] = r.Header.Values(k)
+		}
+	}
+	signer := &sigV4Signer{
+		accessKeyID:     testS3AccessKeyID,
+		secretAccessKey: testS3SecretAccessKey,
+		region:          "us-east-1",
+		service:         "s3",
+		now:             func() time.Time { return date },
+	}
+	if err := signer.sign(req, body); err != nil {
+		return false
+	}
+	return req.Header.Get("Authorization") == auth
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !f.verify(r, body) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("<Error><Code>SignatureDoesNotMatch</Code></Error>"))
+		return
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != "bucket" {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("<Error><Code>NoSuchBucket</Code></Error>"))
+		return
+	}
+	if key == "" && r.Method == http.MethodGet {
+		f.list(w, r)
+		return
+	}
+	for _, h := range []string{"If-Match", "If-None-Match"} {
+		if v := r.Header.Get(h); v != "" {
+			f.headers = append(f.headers, r.Method+" "+h+": "+v)
+		}
+	}
+	b, exists := f.objects[key]
+	if f.conditional {
+		if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if ifMatch != fakeETag(b) {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+		}
+		if r.Header.Get("If-None-Match") == "*" && exists {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+	}
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[key] = body
+		w.Header().Set("ETag", fakeETag(body))
+	case http.MethodGet, http.MethodHead:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", fakeETag(b))
+		w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+		if r.Method == http.MethodGet {
+			w.Write(b)
+		}
+	case http.MethodDelete:
+		// Deleting a non-existent object succeeds, as the S3.
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// list mimics the ListObjectsV2, where the continuation token is simply the last key of the previous page.
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("list-type") != "2" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	prefix, delimiter, token := q.Get("prefix"), q.Get("delimiter"), q.Get("continuation-token")
+	var keys []string
+	for key := range f.objects {
+		if !strings.HasPrefix(key, prefix) || key <= token {
+			continue
+		}
+		if delimiter != "" && strings.Contains(strings.TrimPrefix(key, prefix), delimiter) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	type content struct {
+		Key string
+	}
+	result := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Contents              []content
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+	}{}
+	if len(keys) > f.maxKeys {
+		keys = keys[:f.maxKeys]
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[len(keys)-1]
+	}
+	for _, key := range keys {
+		result.Contents = append(result.Contents, content{Key: key})
+	}
+	b, err := xml.Marshal(result)
+	require.NoError(f.t, err)
+	w.Write(b)
+}
+
+func newTestS3Client(t *testing.T, url string, conditional bool) Client {
+	c, err := NewS3Client("bucket", &S3ClientOption{
+		Endpoint:          url,
+		Prefix:            "demo/",
+		PathStyle:         true,
+		AccessKeyID:       testS3AccessKeyID,
+		SecretAccessKey:   testS3SecretAccessKey,
+		ConditionalWrites: conditional,
+	})
+	require.NoError(t, err)
+	return c
+}
+
+func TestS3Client(t *testing.T) {
+	for _, conditional := range []bool{false, true} {
+		t.Run(fmt.Sprintf("conditional=%t", conditional), func(t *testing.T) {
+			ctx := context.Background()
+			s3 := newFakeS3(t, conditional)
+			srv := httptest.NewServer(s3)
+			defer srv.Close()
+			c := newTestS3Client(t, srv.URL, conditional)
+
+			id, err := c.Create(ctx, []byte(`{"a": 1}`))
+			require.NoError(t, err)
+			require.Contains(t, s3.objects, "demo/"+id)
+			b, version, err := c.Read(ctx, id)
+			require.NoError(t, err)
+			require.JSONEq(t, `{"a": 1}`, string(b))
+			require.Equal(t, fakeETag(b), version)
+
+			require.NoError(t, c.Update(ctx, id, []byte(`{"a": 2}`), version))
+			require.ErrorIs(t, c.Update(ctx, id, []byte(`{"a": 3}`), version), ErrConflict)
+			_, version, err = c.Read(ctx, id)
+			require.NoError(t, err)
+			require.NoError(t, c.Patch(ctx, id, []byte(`{"b": 1}`), version))
+			require.ErrorIs(t, c.Patch(ctx, id, []byte(`{"b": 2}`), version), ErrConflict)
+			b, _, err = c.Read(ctx, id)
+			require.NoError(t, err)
+			require.JSONEq(t, `{"a": 2, "b": 1}`, string(b))
+
+			require.ErrorIs(t, c.Delete(ctx, id, version), ErrConflict)
+			require.NoError(t, c.Delete(ctx, id, ""))
+			_, _, err = c.Read(ctx, id)
+			require.ErrorIs(t, err, ErrNotFound)
+			require.ErrorIs(t, c.Delete(ctx, id, ""), ErrNotFound)
+			require.ErrorIs(t, c.Update(ctx, id, []byte(`{}`), ""), ErrNotFound)
+
+			if conditional {
+				require.Equal(t, []string{
+					"PUT If-None-Match: *",
+					"PUT If-Match: " + fakeETag([]byte(`{"a": 1}`)),
+					"PUT If-Match: " + fakeETag([]byte(`{"a": 2}`)),
+					"DELETE If-Match: " + fakeETag(b),
+				}, s3.headers)
+			} else {
+				require.Empty(t, s3.headers)
+			}
+		})
+	}
+}
+
+func TestS3ClientList(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t, true)
+	srv := httptest.NewServer(s3)
+	defer srv.Close()
+	c := newTestS3Client(t, srv.URL, true)
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		id, err := c.Create(ctx, []byte(fmt.Sprintf(`{"i": %d}`, i)))
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	// The objects out of the prefix, or in its "subdirectories", are not resources.
+	s3.objects["other"] = []byte(`{}`)
+	s3.objects["demo/sub/x"] = []byte(`{}`)
+
+	objs, err := c.List(ctx, true)
+	require.NoError(t, err)
+	var gotIDs []string
+	for _, obj := range objs {
+		gotIDs = append(gotIDs, obj.ID)
+		b, _, err := c.Read(ctx, obj.ID)
+		require.NoError(t, err)
+		require.Equal(t, b, obj.Content)
+	}
+	require.ElementsMatch(t, ids, gotIDs)
+}
+
+func TestS3ClientSignature(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(newFakeS3(t, false))
+	defer srv.Close()
+
+	c, err := NewS3Client("bucket", &S3ClientOption{
+		Endpoint:        srv.URL,
+		PathStyle:       true,
+		AccessKeyID:     testS3AccessKeyID,
+		SecretAccessKey: "wrong",
+	})
+	require.NoError(t, err)
+	_, err = c.Create(ctx, []byte(`{}`))
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+
+	_, err = NewS3Client("bucket", &S3ClientOption{AccessKeyID: testS3AccessKeyID})
+	require.Error(t, err, "missing secret access key")
+}
+
+func TestS3ClientURL(t *testing.T) {
+	c, err := NewS3Client("bucket", &S3ClientOption{Region: "eu-west-1", Prefix: "a b/"})
+	require.NoError(t, err)
+	u := c.(*S3Client).objectURL("id")
+	require.Equal(t, "https://bucket.s3.eu-west-1.amazonaws.com/a%20b/id", u.String())
+
+	c, err = NewS3Client("bucket", &S3ClientOption{Endpoint: "http://localhost:9000/base", PathStyle: true})
+	require.NoError(t, err)
+	u = c.(*S3Client).objectURL("id")
+	require.Equal(t, "http://localhost:9000/base/bucket/id", u.String())
+}
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// gzipMagic is the magic number at the beginning of the gzip stream, which tells the compressed content from the
+// plain JSON.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// compress compresses the content by gzip.
+func compress(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// isCompressed tells whether the content is compressed by gzip.
+func isCompressed(b []byte) bool {
+	return bytes.HasPrefix(b, gzipMagic)
+}
+
+// decompress decompresses the gzip compressed content. The content that is not compressed is returned as is.
+func decompress(b []byte) ([]byte, error) {
+	if !isCompressed(b) {
+		return b, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// encryptionMagic is the prefix of the encrypted envelope, which is in the form of:
+//
+//	$demo-enc$v1$<key id>$<base64 encoded nonce and ciphertext>
+//
+// The header (i.e. everything before the payload) is authenticated as the additional data, so that the key id can't be
+// tampered.
+const encryptionMagic = "$demo-enc$v1$"
+
+// EncryptionKeySize is the size of the encryption keys, which are AES-256 keys.
+const EncryptionKeySize = 32
+
+type EncryptionOption struct {
+	// KeyID identifies the key, which is recorded in the envelope to find the key for decryption.
+	KeyID string
+	// Key is the key used for both encryption and decryption.
+	Key []byte
+	// PreviousKeys are the keys by their ids, which are only used for decrypting the envelopes encrypted before the
+	// key rotation.
+	PreviousKeys map[string][]byte
+}
+
+// DecodeEncryptionKey decodes the base64 encoded encryption key.
+func DecodeEncryptionKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("decoding the encryption key: %v", err)
+	}
+	if len(key) != EncryptionKeySize {
+		return nil, fmt.Errorf("the encryption key must be %d bytes, got %d", EncryptionKeySize, len(key))
+	}
+	return key, nil
+}
+
+// encryptor encrypts the content with AES-256-GCM, and decrypts it with any of the known keys.
+type encryptor struct {
+	keyID string
+	aeads map[string]cipher.AEAD
+}
+
+func newEncryptor(opt *EncryptionOption) (*encryptor, error) {
+	e := &encryptor{keyID: opt.KeyID, aeads: map[string]cipher.AEAD{}}
+	keys := map[string][]byte{}
+	for id, key := range opt.PreviousKeys {
+		keys[id] = key
+	}
+	keys[opt.KeyID] = opt.Key
+	for id, key := range keys {
+		if id == "" || strings.ContainsAny(id, "$\r\n") {
+			return nil, fmt.Errorf("invalid key id %q", id)
+		}
+		if len(key) != EncryptionKeySize {
+			return nil, fmt.Errorf("the key %q must be %d bytes, got %d", id, EncryptionKeySize, len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		e.aeads[id] = aead
+	}
+	return e, nil
+}
+
+func (e *encryptor) encrypt(plaintext []byte) ([]byte, error) {
+	aead := e.aeads[e.keyID]
+	header := encryptionMagic + e.keyID + "$"
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	payload := aead.Seal(nonce, nonce, plaintext, []byte(header))
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	buf.WriteString(base64.StdEncoding.EncodeToString(payload))
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// decrypt decrypts the envelope. The content that is not encrypted is returned as is, so that the plaintext written
+// before the encryption is enabled can still be read.
+func (e *encryptor) decrypt(b []byte) ([]byte, error) {
+	keyID, ok := envelopeKeyID(b)
+	if !ok {
+		return b, nil
+	}
+	if e == nil {
+		return nil, errors.New("the content is encrypted, but no encryption key is configured")
+	}
+	aead, ok := e.aeads[keyID]
+	if !ok {
+		return nil, fmt.Errorf("the content is encrypted by the unknown key %q", keyID)
+	}
+	header := encryptionMagic + keyID + "$"
+	payload, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b[len(header):])))
+	if err != nil {
+		return nil, fmt.Errorf("decoding the encrypted content: %v", err)
+	}
+	if len(payload) < aead.NonceSize() {
+		return nil, errors.New("the encrypted content is truncated")
+	}
+	nonce, ciphertext := payload[:aead.NonceSize()], payload[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(header))
+	if err != nil {
+		return nil, fmt.Errorf("decrypting the content by the key %q: %v", keyID, err)
+	}
+	return plaintext, nil
+}
+
+// envelopeKeyID returns the key id of the encrypted envelope, or false if the content is not encrypted.
+func envelopeKeyID(b []byte) (string, bool) {
+	if !bytes.HasPrefix(b, []byte(encryptionMagic)) {
+		return "", false
+	}
+	rest := b[len(encryptionMagic):]
+	i := bytes.IndexByte(rest, '$')
+	if i < 0 {
+		// The malformed envelope, which fails the decryption due to the unknown key.
+		return "", true
+	}
+	return string(rest[:i]), true
+}
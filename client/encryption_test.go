@@ -0,0 +1,82 @@
+package client
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testEncryptionKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, EncryptionKeySize)
+}
+
+func TestEncryptor(t *testing.T) {
+	e, err := newEncryptor(&EncryptionOption{KeyID: "k1", Key: testEncryptionKey(1)})
+	require.NoError(t, err)
+	plaintext := []byte(`{"password": "secret"}`)
+	b, err := e.encrypt(plaintext)
+	require.NoError(t, err)
+	require.NotContains(t, string(b), "secret", "the content is encrypted")
+	keyID, ok := envelopeKeyID(b)
+	require.True(t, ok)
+	require.Equal(t, "k1", keyID, "the key id is recorded in the envelope")
+	b2, err := e.encrypt(plaintext)
+	require.NoError(t, err)
+	require.NotEqual(t, b, b2, "the nonce is random")
+
+	got, err := e.decrypt(b)
+	require.NoError(t, err)
+	require.Equal(t, plaintext, got, "decrypt")
+	got, err = e.decrypt(plaintext)
+	require.NoError(t, err)
+	require.Equal(t, plaintext, got, "the plaintext is returned as is")
+
+	// Tamper the key id in the header.
+	e2, err := newEncryptor(&EncryptionOption{KeyID: "k2", Key: testEncryptionKey(1)})
+	require.NoError(t, err)
+	_, err = e2.decrypt(bytes.Replace(b, []byte("$k1$"), []byte("$k2$"), 1))
+	require.Error(t, err, "the header is authenticated")
+
+	// Tamper the ciphertext.
+	tampered := append([]byte{}, b...)
+	tampered[len(tampered)-5] ^= 1
+	_, err = e.decrypt(tampered)
+	require.Error(t, err, "the ciphertext is authenticated")
+
+	_, err = e2.decrypt(b)
+	require.ErrorContains(t, err, `unknown key "k1"`)
+	var nilEncryptor *encryptor
+	_, err = nilEncryptor.decrypt(b)
+	require.ErrorContains(t, err, "no encryption key is configured")
+
+	// Rotated, with the previous key.
+	e3, err := newEncryptor(&EncryptionOption{KeyID: "k2", Key: testEncryptionKey(2), PreviousKeys: map[string][]byte{"k1": testEncryptionKey(1)}})
+	require.NoError(t, err)
+	got, err = e3.decrypt(b)
+	require.NoError(t, err)
+	require.Equal(t, plaintext, got, "decrypt by the previous key")
+}
+
+func TestEncryptorInvalidOption(t *testing.T) {
+	for name, opt := range map[string]*EncryptionOption{
+		"empty key id":       {Key: testEncryptionKey(1)},
+		"invalid key id":     {KeyID: "k$1", Key: testEncryptionKey(1)},
+		"short key":          {KeyID: "k1", Key: []byte("short")},
+		"short previous key": {KeyID: "k1", Key: testEncryptionKey(1), PreviousKeys: map[string][]byte{"k0": []byte("short")}},
+	} {
+		_, err := newEncryptor(opt)
+		require.Error(t, err, name)
+	}
+}
+
+func TestDecodeEncryptionKey(t *testing.T) {
+	key, err := DecodeEncryptionKey(base64.StdEncoding.EncodeToString(testEncryptionKey(1)) + "\n")
+	require.NoError(t, err)
+	require.Equal(t, testEncryptionKey(1), key)
+	_, err = DecodeEncryptionKey("not base64")
+	require.Error(t, err)
+	_, err = DecodeEncryptionKey(base64.StdEncoding.EncodeToString([]byte("short")))
+	require.Error(t, err)
+}
@@ -0,0 +1,52 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrNotFound is expected to be returned for `Read` when the resource with the specified id doesn't exist.
+var ErrNotFound = errors.New("resource not found")
+
+// ErrConflict is expected to be returned for the conditional `Update` and `Delete` when the resource's current
+// version doesn't match the specified one, which means the resource has been modified since it was last read.
+var ErrConflict = errors.New("resource version conflict")
+
+// ErrUnauthorized means the request is rejected as the credential is missing or invalid.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// ErrForbidden means the request is rejected as the credential has no permission.
+var ErrForbidden = errors.New("forbidden")
+
+// ErrInvalidRequest means the request is rejected as invalid, e.g. a malformed payload.
+var ErrInvalidRequest = errors.New("invalid request")
+
+// APIError is returned when the server responds with an unexpected status code. It matches the sentinel errors
+// above by `errors.Is` according to the status code, e.g. a 401 matches ErrUnauthorized.
+type APIError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s %s: unexpected status code: %d. Message: %s", e.Method, e.URL, e.StatusCode, e.Body)
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict || e.StatusCode == http.StatusPreconditionFailed
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrInvalidRequest:
+		return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	sentinels := []error{ErrNotFound, ErrConflict, ErrUnauthorized, ErrForbidden, ErrInvalidRequest}
+	cases := []struct {
+		statusCode int
+		want       error
+	}{
+		{statusCode: 400, want: ErrInvalidRequest},
+		{statusCode: 401, want: ErrUnauthorized},
+		{statusCode: 403, want: ErrForbidden},
+		{statusCode: 404, want: ErrNotFound},
+		{statusCode: 409, want: ErrConflict},
+		{statusCode: 412, want: ErrConflict},
+		{statusCode: 422, want: ErrInvalidRequest},
+		{statusCode: 500},
+	}
+	for _, tt := range cases {
+		err := error(&APIError{StatusCode: tt.statusCode})
+		for _, sentinel := range sentinels {
+			require.Equal(t, sentinel == tt.want, errors.Is(err, sentinel), "status code %d is %v", tt.statusCode, sentinel)
+		}
+	}
+}
+
+func TestClientJSONServerAPIError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("name is required"))
+	}))
+	defer ts.Close()
+	c, _ := NewJSONServerClient(ts.URL+"/posts", nil)
+
+	_, err := c.Create(context.Background(), []byte(`{}`))
+	require.ErrorIs(t, err, ErrInvalidRequest)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, APIError{
+		Method:     "POST",
+		URL:        ts.URL + "/posts",
+		StatusCode: http.StatusBadRequest,
+		Body:       "name is required",
+	}, *apiErr)
+}
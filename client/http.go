@@ -0,0 +1,168 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// httpSender sends the HTTP requests, with the retries, the authentication and the compression. It is shared by the
+// HTTP based clients.
+type httpSender struct {
+	client   *http.Client
+	retry    *RetryOption
+	auth     *authenticator
+	compress bool
+	// sign signs each attempt of the request with its final body, if not nil.
+	sign func(req *http.Request, body []byte) error
+	wire *wireLogger
+}
+
+func newHTTPSender(transport *TransportOption, retry *RetryOption, auth *AuthOption, compress bool, wireLog *WireLogOption) (*httpSender, error) {
+	client, err := newHTTPClient(transport)
+	if err != nil {
+		return nil, err
+	}
+	authenticator, err := newAuthenticator(auth)
+	if err != nil {
+		return nil, err
+	}
+	wire := newWireLogger(wireLog, authenticator.opt.APIKeyHeader)
+	wire.redact = authenticator.redact
+	return &httpSender{
+		client:   client,
+		retry:    retry,
+		auth:     authenticator,
+		compress: compress,
+		wire:     wire,
+	}, nil
+}
+
+func statuscodeMatches(code int, codes ...int) bool {
+	for _, okcode := range codes {
+		if okcode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// response is the HTTP response whose body has been read.
+type response struct {
+	Method     string
+	URL        url.URL
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// do sends the request, with retries, and reads the response body. If the server responds 401 to the token got from
+// the credential helper, the token is refreshed and the request is sent again.
+func (h *httpSender) do(ctx context.Context, method string, u url.URL, header http.Header, body []byte) (*response, error) {
+	resp, token, err := h.send(ctx, method, u, header, body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && h.auth.refreshable() {
+		h.auth.invalidate(token)
+		resp, _, err = h.send(ctx, method, u, header, body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// send sends the authenticated request, with retries, and reads the response body. The token got from the
+// credential helper, if any, is returned. The request and its response are logged to the wire log subsystem.
+func (h *httpSender) send(ctx context.Context, method string, u url.URL, header http.Header, body []byte) (*response, string, error) {
+	start := time.Now()
+	var req *http.Request
+	resp, token, err := h.exchange(ctx, method, u, header, body, &req)
+	h.wire.logHTTP(ctx, req, method, u, body, resp, time.Since(start), err)
+	return resp, token, err
+}
+
+// exchange does the work of send, where the last attempt of the request is stored in last.
+func (h *httpSender) exchange(ctx context.Context, method string, u url.URL, header http.Header, body []byte, last **http.Request) (*response, string, error) {
+	if h.compress {
+		// The header is copied, to not modify the one of the caller.
+		copied := http.Header{}
+		for k, v := range header {
+			copied[k] = v
+		}
+		header = copied
+		// Setting the Accept-Encoding explicitly disables the transparent decompression of the transport, the
+		// response is decompressed below instead.
+		header.Set("Accept-Encoding", "gzip")
+		if body != nil {
+			b, err := compress(body)
+			if err != nil {
+				return nil, "", err
+			}
+			body = b
+			header.Set("Content-Encoding", "gzip")
+		}
+	}
+	var token string
+	resp, err := doWithRetry(ctx, h.client, h.retry, func() (*http.Request, error) {
+		var r io.Reader
+		if body != nil {
+			r = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), r)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if token, err = h.auth.apply(ctx, req); err != nil {
+			return nil, err
+		}
+		if h.sign != nil {
+			if err := h.sign(req, body); err != nil {
+				return nil, err
+			}
+		}
+		*last = req
+		return req, nil
+	})
+	if err != nil {
+		return nil, "", h.auth.redactError(err)
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		if content, err = decompress(content); err != nil {
+			return nil, "", fmt.Errorf("decompressing the response: %w", err)
+		}
+		resp.Header.Del("Content-Encoding")
+	}
+	return &response{
+		Method:     method,
+		URL:        u,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       content,
+	}, token, nil
+}
+
+// statusError returns the APIError for the unexpected status code, with the secrets redacted.
+func (h *httpSender) statusError(resp *response) error {
+	return &APIError{
+		Method:     resp.Method,
+		URL:        resp.URL.Redacted(),
+		StatusCode: resp.StatusCode,
+		Body:       h.auth.redact(string(resp.Body)),
+	}
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPathStep is a step of the JSON path, which is either the name of a child, or the index of an array element.
+type jsonPathStep struct {
+	name  string
+	index int
+}
+
+// parseJSONPath parses the path of the subset of the JSONPath syntax: the root "$", the child ".name" or "['name']",
+// and the array index "[n]". E.g. "$.data.items[0].id".
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid JSON path %q: must start with $", path)
+	}
+	var steps []jsonPathStep
+	rest := path[1:]
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid JSON path %q: empty name", path)
+			}
+			steps = append(steps, jsonPathStep{name: rest[:end], index: -1})
+			rest = rest[end:]
+		case strings.HasPrefix(rest, "['"):
+			end := strings.Index(rest, "']")
+			if end == -1 {
+				return nil, fmt.Errorf("invalid JSON path %q: unterminated bracket", path)
+			}
+			steps = append(steps, jsonPathStep{name: rest[2:end], index: -1})
+			rest = rest[end+2:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end == -1 {
+				return nil, fmt.Errorf("invalid JSON path %q: unterminated bracket", path)
+			}
+			idx, err := strconv.Atoi(rest[1:end])
+			if err != nil || idx < 0 {
+				return nil, fmt.Errorf("invalid JSON path %q: invalid index %q", path, rest[1:end])
+			}
+			steps = append(steps, jsonPathStep{index: idx})
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid JSON path %q: unexpected %q", path, rest)
+		}
+	}
+	return steps, nil
+}
+
+// jsonPathLookup returns the value at the path of the decoded JSON document.
+func jsonPathLookup(doc interface{}, path string) (interface{}, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	v := doc
+	for _, step := range steps {
+		if step.index == -1 {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("JSON path %q: %q is not a child of an object", path, step.name)
+			}
+			if v, ok = m[step.name]; !ok {
+				return nil, fmt.Errorf("JSON path %q: %q not found", path, step.name)
+			}
+			continue
+		}
+		l, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("JSON path %q: index %d of a non-array", path, step.index)
+		}
+		if step.index >= len(l) {
+			return nil, fmt.Errorf("JSON path %q: index %d out of range", path, step.index)
+		}
+		v = l[step.index]
+	}
+	return v, nil
+}
+
+// idString returns the string form of the id value decoded from JSON, which is either a string or a number.
+func idString(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		if v == "" {
+			return "", fmt.Errorf("empty id")
+		}
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("invalid id: %v", v)
+	}
+}
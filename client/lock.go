@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// locker provides advisory locks identified by names. An exclusive lock conflicts with any other lock of the same
+// name, while the shared locks don't conflict with each other. The lock blocks until it is acquired or the context
+// is done.
+type locker interface {
+	lock(ctx context.Context, name string, exclusive bool) (unlock func(), err error)
+}
+
+// acquireLock acquires the lock within the timeout. Zero timeout means waiting until the context is done.
+func acquireLock(ctx context.Context, l locker, name string, exclusive bool, timeout time.Duration) (func(), error) {
+	lctx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		lctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	unlock, err := l.lock(lctx, name, exclusive)
+	if err != nil {
+		if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("timeout acquiring the lock of %q after %s", name, timeout)
+		}
+		return nil, err
+	}
+	return unlock, nil
+}
+
+// memLocker is the in-process locker, used when the locks can't be backed by the filesystem, e.g. afero.MemMapFs.
+type memLocker struct {
+	mu    sync.Mutex
+	locks map[string]*memLock
+}
+
+type memLock struct {
+	readers int
+	writer  bool
+	// released is closed (and replaced) whenever the lock is released, to wake up the waiters.
+	released chan struct{}
+}
+
+func newMemLocker() *memLocker {
+	return &memLocker{locks: map[string]*memLock{}}
+}
+
+func (l *memLocker) lock(ctx context.Context, name string, exclusive bool) (func(), error) {
+	for {
+		l.mu.Lock()
+		lk, ok := l.locks[name]
+		if !ok {
+			lk = &memLock{released: make(chan struct{})}
+			l.locks[name] = lk
+		}
+		if !lk.writer && (!exclusive || lk.readers == 0) {
+			if exclusive {
+				lk.writer = true
+			} else {
+				lk.readers++
+			}
+			l.mu.Unlock()
+			return func() { l.unlock(name, exclusive) }, nil
+		}
+		released := lk.released
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-released:
+		}
+	}
+}
+
+func (l *memLocker) unlock(name string, exclusive bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lk := l.locks[name]
+	if exclusive {
+		lk.writer = false
+	} else {
+		lk.readers--
+	}
+	close(lk.released)
+	lk.released = make(chan struct{})
+	if !lk.writer && lk.readers == 0 {
+		delete(l.locks, name)
+	}
+}
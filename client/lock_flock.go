@@ -0,0 +1,63 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package client
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// flockLocker is the cross-process locker backed by flock(2) on the lock files under dir. Each lock is taken on a
+// separately opened file, so that the locks also work between the goroutines of the same process.
+//
+// The lock files are never removed, as removing a lock file that another process is waiting for breaks the mutual
+// exclusion.
+type flockLocker struct {
+	dir string
+}
+
+func newOsLocker(dir string) (locker, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &flockLocker{dir: dir}, nil
+}
+
+func (l *flockLocker) lock(ctx context.Context, name string, exclusive bool) (func(), error) {
+	f, err := os.OpenFile(filepath.Join(l.dir, name+".lock"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	// flock(2) can't be interrupted by the context, so poll with the non-blocking mode instead.
+	backoff := time.Millisecond
+	for {
+		err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB)
+		if err == nil {
+			return func() {
+				syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+				f.Close()
+			}, nil
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) && !errors.Is(err, syscall.EINTR) {
+			f.Close()
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff < 50*time.Millisecond {
+			backoff *= 2
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package client
+
+// newOsLocker falls back to the in-process locker on the platforms without flock(2), which doesn't protect against
+// the other processes.
+func newOsLocker(dir string) (locker, error) {
+	return newMemLocker(), nil
+}
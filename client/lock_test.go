@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testLocker(t *testing.T, l locker) {
+	ctx := context.Background()
+	tryLock := func(name string, exclusive bool) (func(), error) {
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		return l.lock(ctx, name, exclusive)
+	}
+
+	// The shared locks don't conflict with each other.
+	unlock1, err := tryLock("foo", false)
+	require.NoError(t, err)
+	unlock2, err := tryLock("foo", false)
+	require.NoError(t, err)
+
+	// The exclusive lock conflicts with the shared locks.
+	_, err = tryLock("foo", true)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The locks of different names don't conflict.
+	unlockBar, err := tryLock("bar", true)
+	require.NoError(t, err)
+
+	// The exclusive lock is acquired once all the shared locks are released.
+	done := make(chan error)
+	go func() {
+		unlock, err := l.lock(ctx, "foo", true)
+		if err == nil {
+			unlock()
+		}
+		done <- err
+	}()
+	unlock1()
+	select {
+	case <-done:
+		t.Fatal("exclusive lock acquired while a shared lock is held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock2()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("exclusive lock not acquired after all the shared locks are released")
+	}
+
+	// The exclusive lock conflicts with any lock.
+	_, err = tryLock("bar", false)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = tryLock("bar", true)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	unlockBar()
+	unlock, err := tryLock("bar", true)
+	require.NoError(t, err)
+	unlock()
+}
+
+func TestMemLocker(t *testing.T) {
+	testLocker(t, newMemLocker())
+}
+
+func TestOsLocker(t *testing.T) {
+	l, err := newOsLocker(t.TempDir())
+	require.NoError(t, err)
+	testLocker(t, l)
+}
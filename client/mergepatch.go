@@ -0,0 +1,88 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+)
+
+// MergePatch applies the RFC 7386 JSON merge patch to the JSON document.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var d, p interface{}
+	if err := unmarshalJSON(doc, &d); err != nil {
+		return nil, err
+	}
+	if err := unmarshalJSON(patch, &p); err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergePatch(d, p))
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+// CreateMergePatch returns the RFC 7386 JSON merge patch that transforms the original JSON object to the modified
+// one. Both documents are expected to be JSON objects. Note that the null values in the modified document can't be
+// expressed by the merge patch, they end up removing the corresponding members.
+func CreateMergePatch(original, modified []byte) ([]byte, error) {
+	var o, m map[string]interface{}
+	if err := unmarshalJSON(original, &o); err != nil {
+		return nil, err
+	}
+	if err := unmarshalJSON(modified, &m); err != nil {
+		return nil, err
+	}
+	return json.Marshal(createMergePatch(o, m))
+}
+
+func createMergePatch(original, modified map[string]interface{}) map[string]interface{} {
+	patch := map[string]interface{}{}
+	for k := range original {
+		if _, ok := modified[k]; !ok {
+			patch[k] = nil
+		}
+	}
+	for k, mv := range modified {
+		ov, ok := original[k]
+		if !ok {
+			patch[k] = mv
+			continue
+		}
+		om, ook := ov.(map[string]interface{})
+		mm, mok := mv.(map[string]interface{})
+		if ook && mok {
+			if sub := createMergePatch(om, mm); len(sub) != 0 {
+				patch[k] = sub
+			}
+			continue
+		}
+		if !reflect.DeepEqual(ov, mv) {
+			patch[k] = mv
+		}
+	}
+	return patch
+}
+
+// unmarshalJSON decodes the JSON with the numbers kept as json.Number, so that they are not altered by the float64
+// conversion.
+func unmarshalJSON(b []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
@@ -0,0 +1,184 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// Middleware wraps a client to add a cross-cutting behavior, e.g. logging, to all of its operations.
+type Middleware func(Client) Client
+
+// Chain wraps the client by the middlewares. The first middleware is the outermost one, i.e. it is the first to see
+// each call and the last to see its result.
+func Chain(c Client, middlewares ...Middleware) Client {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		c = middlewares[i](c)
+	}
+	return c
+}
+
+// Wrapper is implemented by the clients wrapping another client, e.g. the ones returned by the middlewares.
+type Wrapper interface {
+	Unwrap() Client
+}
+
+// Unwrap returns the innermost client, by unwrapping the Wrapper repeatedly. It is used to reach the optional
+// interfaces of the backend that the middlewares don't forward, e.g. the CollectionClient. The HistoryClient is
+// forwarded by the middlewares, as long as the wrapped client implements it.
+func Unwrap(c Client) Client {
+	for {
+		w, ok := c.(Wrapper)
+		if !ok {
+			return c
+		}
+		c = w.Unwrap()
+	}
+}
+
+// Operation describes a call to the client.
+type Operation struct {
+	// Name is the name of the called method, e.g. "Create".
+	Name string
+	// ID is the id of the resource. It is empty for the List, and is only known once the Create succeeds.
+	ID string
+}
+
+// Interceptor is called around each operation of the client. It calls next to carry out the operation, and returns
+// its error, or another error instead.
+type Interceptor func(ctx context.Context, op *Operation, next func(ctx context.Context) error) error
+
+// Intercept returns the middleware calling the interceptor around each operation of the client, including the ones of
+// the HistoryClient, if the client implements it.
+func Intercept(interceptor Interceptor) Middleware {
+	return func(c Client) Client {
+		ic := &interceptedClient{next: c, interceptor: interceptor}
+		if hc, ok := c.(HistoryClient); ok {
+			return &interceptedHistoryClient{interceptedClient: ic, history: hc}
+		}
+		return ic
+	}
+}
+
+type interceptedClient struct {
+	next        Client
+	interceptor Interceptor
+}
+
+func (c *interceptedClient) Unwrap() Client {
+	return c.next
+}
+
+func (c *interceptedClient) Create(ctx context.Context, b []byte) (string, error) {
+	op := &Operation{Name: "Create"}
+	err := c.interceptor(ctx, op, func(ctx context.Context) error {
+		id, err := c.next.Create(ctx, b)
+		op.ID = id
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return op.ID, nil
+}
+
+func (c *interceptedClient) Read(ctx context.Context, id string) ([]byte, string, error) {
+	var (
+		b       []byte
+		version string
+	)
+	err := c.interceptor(ctx, &Operation{Name: "Read", ID: id}, func(ctx context.Context) (err error) {
+		b, version, err = c.next.Read(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return b, version, nil
+}
+
+func (c *interceptedClient) Update(ctx context.Context, id string, b []byte, version string) error {
+	return c.interceptor(ctx, &Operation{Name: "Update", ID: id}, func(ctx context.Context) error {
+		return c.next.Update(ctx, id, b, version)
+	})
+}
+
+func (c *interceptedClient) Patch(ctx context.Context, id string, patch []byte, version string) error {
+	return c.interceptor(ctx, &Operation{Name: "Patch", ID: id}, func(ctx context.Context) error {
+		return c.next.Patch(ctx, id, patch, version)
+	})
+}
+
+func (c *interceptedClient) Delete(ctx context.Context, id string, version string) error {
+	return c.interceptor(ctx, &Operation{Name: "Delete", ID: id}, func(ctx context.Context) error {
+		return c.next.Delete(ctx, id, version)
+	})
+}
+
+func (c *interceptedClient) List(ctx context.Context, withContent bool) ([]Object, error) {
+	var objects []Object
+	err := c.interceptor(ctx, &Operation{Name: "List"}, func(ctx context.Context) (err error) {
+		objects, err = c.next.List(ctx, withContent)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// interceptedHistoryClient is the interceptedClient of the client implementing the HistoryClient.
+type interceptedHistoryClient struct {
+	*interceptedClient
+	history HistoryClient
+}
+
+var _ HistoryClient = &interceptedHistoryClient{}
+
+func (c *interceptedHistoryClient) History(ctx context.Context, id string) ([]Revision, error) {
+	var revisions []Revision
+	err := c.interceptor(ctx, &Operation{Name: "History", ID: id}, func(ctx context.Context) (err error) {
+		revisions, err = c.history.History(ctx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (c *interceptedHistoryClient) Restore(ctx context.Context, id string, version string) error {
+	return c.interceptor(ctx, &Operation{Name: "Restore", ID: id}, func(ctx context.Context) error {
+		return c.history.Restore(ctx, id, version)
+	})
+}
+
+// Logger logs a message with the structured fields.
+type Logger func(ctx context.Context, msg string, fields map[string]interface{})
+
+// LoggingMiddleware logs each operation of the client once it is done, together with its error, if any.
+func LoggingMiddleware(logger Logger) Middleware {
+	return Intercept(func(ctx context.Context, op *Operation, next func(ctx context.Context) error) error {
+		err := next(ctx)
+		fields := map[string]interface{}{
+			"operation": op.Name,
+		}
+		if op.ID != "" {
+			fields["id"] = op.ID
+		}
+		if err != nil {
+			fields["error"] = err.Error()
+		}
+		logger(ctx, "client operation", fields)
+		return err
+	})
+}
+
+// TimingMiddleware reports the duration of each operation of the client, together with its error, if any.
+func TimingMiddleware(observe func(ctx context.Context, op Operation, d time.Duration, err error)) Middleware {
+	return Intercept(func(ctx context.Context, op *Operation, next func(ctx context.Context) error) error {
+		start := time.Now()
+		err := next(ctx)
+		observe(ctx, *op, time.Since(start), err)
+		return err
+	})
+}
@@ -0,0 +1,159 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// tracingMiddleware records the entering and leaving of each operation into the trace.
+func tracingMiddleware(name string, trace *[]string) Middleware {
+	return Intercept(func(ctx context.Context, op *Operation, next func(ctx context.Context) error) error {
+		*trace = append(*trace, fmt.Sprintf("%s enter %s", name, op.Name))
+		err := next(ctx)
+		*trace = append(*trace, fmt.Sprintf("%s leave %s %s", name, op.Name, op.ID))
+		return err
+	})
+}
+
+func TestChain(t *testing.T) {
+	ctx := context.Background()
+	var trace []string
+	inner := NewMemoryClient()
+	c := Chain(inner, tracingMiddleware("a", &trace), tracingMiddleware("b", &trace))
+
+	id, err := c.Create(ctx, []byte(`{}`))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"a enter Create",
+		"b enter Create",
+		"b leave Create " + id,
+		"a leave Create " + id,
+	}, trace)
+
+	b, version, err := c.Read(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, `{}`, string(b))
+	require.NoError(t, c.Update(ctx, id, []byte(`{"a": 1}`), version))
+	require.NoError(t, c.Patch(ctx, id, []byte(`{"b": 1}`), ""))
+	objs, err := c.List(ctx, true)
+	require.NoError(t, err)
+	require.Len(t, objs, 1)
+	require.JSONEq(t, `{"a": 1, "b": 1}`, string(objs[0].Content))
+	require.NoError(t, c.Delete(ctx, id, ""))
+
+	require.Same(t, inner, Unwrap(c))
+	require.Same(t, inner, Unwrap(inner))
+	require.Same(t, inner, Chain(inner))
+}
+
+func TestChainHistory(t *testing.T) {
+	ctx := context.Background()
+	var trace []string
+	inner, err := newFsClient(afero.NewMemMapFs(), "/tmp", &FsClientOption{HistoryLimit: 2})
+	require.NoError(t, err)
+	c := Chain(inner, tracingMiddleware("a", &trace))
+	hc, ok := c.(HistoryClient)
+	require.True(t, ok, "the history client is forwarded")
+
+	id, err := c.Create(ctx, []byte(`{"v": 1}`))
+	require.NoError(t, err)
+	require.NoError(t, c.Update(ctx, id, []byte(`{"v": 2}`), ""))
+	revs, err := hc.History(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, revs, 1)
+	require.NoError(t, hc.Restore(ctx, id, revs[0].Version))
+	require.Equal(t, []string{
+		"a enter Create",
+		"a leave Create " + id,
+		"a enter Update",
+		"a leave Update " + id,
+		"a enter History",
+		"a leave History " + id,
+		"a enter Restore",
+		"a leave Restore " + id,
+	}, trace)
+
+	_, ok = Chain(NewMemoryClient(), tracingMiddleware("a", &trace)).(HistoryClient)
+	require.False(t, ok, "the history client is not made up")
+}
+
+func TestChainError(t *testing.T) {
+	ctx := context.Background()
+	var trace []string
+	errInjected := errors.New("injected")
+	// The fault injection middleware fails the deletes without calling the inner client.
+	fault := Intercept(func(ctx context.Context, op *Operation, next func(ctx context.Context) error) error {
+		if op.Name == "Delete" {
+			return errInjected
+		}
+		return next(ctx)
+	})
+	inner := NewMemoryClient()
+	c := Chain(inner, tracingMiddleware("outer", &trace), fault, tracingMiddleware("inner", &trace))
+
+	_, _, err := c.Read(ctx, "x")
+	require.ErrorIs(t, err, ErrNotFound, "the error of the client is propagated")
+	require.Equal(t, []string{
+		"outer enter Read",
+		"inner enter Read",
+		"inner leave Read x",
+		"outer leave Read x",
+	}, trace)
+
+	trace = nil
+	id, err := inner.Create(ctx, []byte(`{}`))
+	require.NoError(t, err)
+	require.ErrorIs(t, c.Delete(ctx, id, ""), errInjected, "the error of the middleware is propagated")
+	require.Equal(t, []string{
+		"outer enter Delete",
+		"outer leave Delete " + id,
+	}, trace)
+	_, _, err = inner.Read(ctx, id)
+	require.NoError(t, err, "the inner client is not called")
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	ctx := context.Background()
+	var logs []map[string]interface{}
+	c := Chain(NewMemoryClient(), LoggingMiddleware(func(ctx context.Context, msg string, fields map[string]interface{}) {
+		logs = append(logs, fields)
+	}))
+	id, err := c.Create(ctx, []byte(`{}`))
+	require.NoError(t, err)
+	require.Error(t, c.Delete(ctx, "x", ""))
+	require.Equal(t, []map[string]interface{}{
+		{"operation": "Create", "id": id},
+		{"operation": "Delete", "id": "x", "error": ErrNotFound.Error()},
+	}, logs)
+}
+
+func TestTimingMiddleware(t *testing.T) {
+	ctx := context.Background()
+	type observation struct {
+		op  Operation
+		err error
+	}
+	var observations []observation
+	slow := Intercept(func(ctx context.Context, op *Operation, next func(ctx context.Context) error) error {
+		time.Sleep(10 * time.Millisecond)
+		return next(ctx)
+	})
+	c := Chain(NewMemoryClient(), TimingMiddleware(func(ctx context.Context, op Operation, d time.Duration, err error) {
+		require.GreaterOrEqual(t, d, 10*time.Millisecond)
+		observations = append(observations, observation{op, err})
+	}), slow)
+	_, err := c.List(ctx, false)
+	require.NoError(t, err)
+	_, _, err = c.Read(ctx, "x")
+	require.ErrorIs(t, err, ErrNotFound)
+	require.Equal(t, []observation{
+		{Operation{Name: "List"}, nil},
+		{Operation{Name: "Read", ID: "x"}, ErrNotFound},
+	}, observations)
+}
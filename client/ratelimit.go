@@ -0,0 +1,175 @@
+package client
+
+import (
+	"container/list"
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimitOption configures how the operations of a client are throttled. An operation might send more than one
+// request to the backend service, e.g. the Patch of the S3Client, which is still limited as a whole.
+type RateLimitOption struct {
+	// RequestsPerSecond is the rate of the operations allowed in the long run. Zero means no rate limit.
+	RequestsPerSecond float64
+	// Burst is the number of the operations allowed at once, beyond the rate. Defaults to 1.
+	Burst int
+	// MaxInFlight is the maximum number of the concurrent operations. Zero means no limit.
+	MaxInFlight int
+}
+
+// RateLimitMiddleware throttles the operations of the client by a token bucket and a semaphore. The operations are
+// admitted in the order they arrive, and stop waiting once their contexts are done.
+func RateLimitMiddleware(opt RateLimitOption) Middleware {
+	var (
+		bucket *tokenBucket
+		sem    *semaphore
+	)
+	if opt.RequestsPerSecond > 0 {
+		bucket = newTokenBucket(opt.RequestsPerSecond, opt.Burst)
+	}
+	if opt.MaxInFlight > 0 {
+		sem = newSemaphore(opt.MaxInFlight)
+	}
+	return Intercept(func(ctx context.Context, op *Operation, next func(ctx context.Context) error) error {
+		// The slot is taken before the token, so that the tokens are not used up by the operations waiting for the
+		// slots, which would be sent in a burst then.
+		if sem != nil {
+			if err := sem.acquire(ctx); err != nil {
+				return err
+			}
+			defer sem.release()
+		}
+		if bucket != nil {
+			if err := bucket.wait(ctx); err != nil {
+				return err
+			}
+		}
+		return next(ctx)
+	})
+}
+
+// tokenBucket is the token bucket, filled at the rate up to the burst. Each waiter reserves a token in the order of
+// arrival, where the tokens might go negative, then waits until its token is filled.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+	}
+}
+
+// reserve takes a token, and returns how long to wait until it is filled.
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// unreserve gives back the token of a canceled reservation.
+func (b *tokenBucket) unreserve() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(b.burst, b.tokens+1)
+}
+
+// wait waits for a token until the context is done.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	d := b.reserve()
+	if d == 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		b.unreserve()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// semaphore is the counting semaphore, whose waiters are admitted in the order of arrival.
+type semaphore struct {
+	mu      sync.Mutex
+	size    int
+	inUse   int
+	waiters list.List
+}
+
+func newSemaphore(size int) *semaphore {
+	return &semaphore{size: size}
+}
+
+// acquire takes a slot, waiting until the context is done.
+func (s *semaphore) acquire(ctx context.Context) error {
+	s.mu.Lock()
+	if err := ctx.Err(); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	if s.inUse < s.size && s.waiters.Len() == 0 {
+		s.inUse++
+		s.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	elem := s.waiters.PushBack(ready)
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		select {
+		case <-ready:
+			// The slot is handed over right after the context is done, pass it on.
+			s.mu.Unlock()
+			s.release()
+		default:
+			s.waiters.Remove(elem)
+			s.mu.Unlock()
+		}
+		return ctx.Err()
+	}
+}
+
+// release gives back the slot, which is handed over to the first waiter, if any.
+func (s *semaphore) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if front := s.waiters.Front(); front != nil {
+		s.waiters.Remove(front)
+		close(front.Value.(chan struct{}))
+		return
+	}
+	s.inUse--
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTokenBucket(10, 2)
+	b.now = func() time.Time { return now }
+
+	require.Equal(t, time.Duration(0), b.reserve())
+	require.Equal(t, time.Duration(0), b.reserve())
+	require.Equal(t, 100*time.Millisecond, b.reserve())
+	require.Equal(t, 200*time.Millisecond, b.reserve())
+	now = now.Add(time.Second)
+	// The bucket is refilled up to the burst.
+	require.Equal(t, time.Duration(0), b.reserve())
+	require.Equal(t, time.Duration(0), b.reserve())
+	require.Equal(t, 100*time.Millisecond, b.reserve())
+	b.unreserve()
+	require.Equal(t, 100*time.Millisecond, b.reserve())
+}
+
+func TestTokenBucketCanceled(t *testing.T) {
+	b := newTokenBucket(1, 1)
+	require.NoError(t, b.wait(context.Background()))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, b.wait(ctx), context.DeadlineExceeded)
+	require.InDelta(t, 0, b.tokens, 0.1, "the token of the canceled waiter is given back")
+}
+
+func TestSemaphore(t *testing.T) {
+	s := newSemaphore(1)
+	require.NoError(t, s.acquire(context.Background()))
+
+	// The waiters are admitted in the order of arrival.
+	var (
+		mu    sync.Mutex
+		order []int
+		wg    sync.WaitGroup
+	)
+	for i := 0; i < 5; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, s.acquire(context.Background()))
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			s.release()
+		}()
+		require.Eventually(t, func() bool {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return s.waiters.Len() == i+1
+		}, time.Second, time.Millisecond)
+	}
+
+	// The canceled waiter leaves the queue.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, s.acquire(ctx), context.DeadlineExceeded)
+	require.Equal(t, 5, s.waiters.Len())
+
+	s.release()
+	wg.Wait()
+	require.Equal(t, []int{0, 1, 2, 3, 4}, order)
+	require.Equal(t, 0, s.inUse)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	ctx := context.Background()
+	var inFlight, maxInFlight int32
+	slow := Intercept(func(ctx context.Context, op *Operation, next func(ctx context.Context) error) error {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return next(ctx)
+	})
+	c := Chain(NewMemoryClient(), RateLimitMiddleware(RateLimitOption{
+		RequestsPerSecond: 100,
+		Burst:             2,
+		MaxInFlight:       3,
+	}), slow)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 12; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.Create(ctx, []byte(`{}`))
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	// The 10 operations beyond the burst take at least 100ms at the rate of 100/s.
+	require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	require.LessOrEqual(t, maxInFlight, int32(3))
+	require.Equal(t, 12, Unwrap(c).(*MemoryClient).Len())
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err := c.List(canceled, false)
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryOption configures how the failed HTTP requests are retried.
+type RetryOption struct {
+	// MaxAttempts is the maximum number of attempts of a request, including the first one.
+	MaxAttempts int
+	// BaseBackoff is the backoff before the first retry, which is doubled for each following retry.
+	BaseBackoff time.Duration
+	// MaxBackoff caps the exponentially growing backoff, as well as the delay asked by the "Retry-After" header.
+	MaxBackoff time.Duration
+	// Jitter randomizes the backoff, to avoid concurrent requests retrying in lockstep.
+	Jitter bool
+}
+
+// DefaultRetryOption returns the retry option used when only part of the option is specified.
+func DefaultRetryOption() RetryOption {
+	return RetryOption{
+		MaxAttempts: 4,
+		BaseBackoff: 500 * time.Millisecond,
+		MaxBackoff:  30 * time.Second,
+		Jitter:      true,
+	}
+}
+
+// backoff returns the backoff before the n-th (starting from 1) retry.
+func (opt RetryOption) backoff(n int) time.Duration {
+	d := opt.BaseBackoff
+	for i := 1; i < n && (opt.MaxBackoff <= 0 || d < opt.MaxBackoff); i++ {
+		d *= 2
+	}
+	if opt.MaxBackoff > 0 && d > opt.MaxBackoff {
+		d = opt.MaxBackoff
+	}
+	if opt.Jitter && d > 0 {
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	}
+	return d
+}
+
+// retryDelay returns the delay before the n-th (starting from 1) retry. The "Retry-After" header of the response,
+// if any, takes precedence over the backoff, while it is still capped by the MaxBackoff.
+func (opt RetryOption) retryDelay(n int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			if opt.MaxBackoff > 0 && d > opt.MaxBackoff {
+				d = opt.MaxBackoff
+			}
+			return d
+		}
+	}
+	return opt.backoff(n)
+}
+
+// retryAfter parses the "Retry-After" header, which is either a number of seconds or an HTTP date.
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if sec, err := strconv.Atoi(v); err == nil {
+		if sec < 0 {
+			return 0, false
+		}
+		return time.Duration(sec) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// shouldRetry tells whether a request is worth retrying, given its response or error.
+//
+// The idempotent requests are retried on any transport error, and on the status codes indicating a transient
+// failure. The non-idempotent requests (i.e. POST and PATCH) are only retried when it is certain that the server hasn't
+// processed it, i.e. the connection can't be established, or the server explicitly rejects it by 429 or 503.
+func shouldRetry(method string, resp *http.Response, err error) bool {
+	idempotent := method != http.MethodPost && method != http.MethodPatch
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		if idempotent {
+			return true
+		}
+		var opErr *net.OpError
+		return errors.As(err, &opErr) && opErr.Op == "dial"
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent
+	}
+	return false
+}
+
+// doWithRetry sends the request built by newRequest, and retries it according to the retry option. The newRequest
+// is called for each attempt, as the request body can't be reused. A nil option means no retry at all.
+func doWithRetry(ctx context.Context, client *http.Client, opt *RetryOption, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	maxAttempts := 1
+	if opt != nil && opt.MaxAttempts > 1 {
+		maxAttempts = opt.MaxAttempts
+	}
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if attempt >= maxAttempts || !shouldRetry(req.Method, resp, err) {
+			return resp, err
+		}
+		delay := opt.retryDelay(attempt, resp)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
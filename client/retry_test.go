@@ -0,0 +1,223 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// faultHandler injects failures in front of the testHandler. The first n requests of the matching method get
+// failed, either by the status code, or by resetting the connection if the status code is 0.
+type faultHandler struct {
+	h          *testHandler
+	method     string
+	n          int64
+	statusCode int
+	retryAfter string
+
+	count    int64
+	attempts int64
+}
+
+func (f *faultHandler) Handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != f.method {
+		f.h.Handle(w, r)
+		return
+	}
+	atomic.AddInt64(&f.attempts, 1)
+	if atomic.AddInt64(&f.count, 1) > f.n {
+		f.h.Handle(w, r)
+		return
+	}
+	if f.statusCode == 0 {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			panic(err)
+		}
+		conn.(*net.TCPConn).SetLinger(0)
+		conn.Close()
+		return
+	}
+	if f.retryAfter != "" {
+		w.Header().Set("Retry-After", f.retryAfter)
+	}
+	w.WriteHeader(f.statusCode)
+}
+
+func newFaultTestServer(f *faultHandler) *httptest.Server {
+	f.h = &testHandler{
+		buf: map[string]map[string]interface{}{},
+	}
+	return httptest.NewServer(http.HandlerFunc(f.Handle))
+}
+
+func TestRetry(t *testing.T) {
+	retry := &RetryOption{
+		MaxAttempts: 3,
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  10 * time.Millisecond,
+	}
+	cases := []struct {
+		name         string
+		fault        faultHandler
+		retry        *RetryOption
+		wantErr      bool
+		wantAttempts int64
+	}{
+		{
+			name:         "get 503 recovered",
+			fault:        faultHandler{method: http.MethodGet, n: 2, statusCode: 503},
+			retry:        retry,
+			wantAttempts: 3,
+		},
+		{
+			name:         "get 503 exhausted",
+			fault:        faultHandler{method: http.MethodGet, n: 3, statusCode: 503},
+			retry:        retry,
+			wantErr:      true,
+			wantAttempts: 3,
+		},
+		{
+			name:         "get 503 no retry",
+			fault:        faultHandler{method: http.MethodGet, n: 1, statusCode: 503},
+			wantErr:      true,
+			wantAttempts: 1,
+		},
+		{
+			name:         "get connection reset recovered",
+			fault:        faultHandler{method: http.MethodGet, n: 2},
+			retry:        retry,
+			wantAttempts: 3,
+		},
+		{
+			name:         "get 500 not retried",
+			fault:        faultHandler{method: http.MethodGet, n: 1, statusCode: 500},
+			retry:        retry,
+			wantErr:      true,
+			wantAttempts: 1,
+		},
+		{
+			name:         "put 429 with retry-after recovered",
+			fault:        faultHandler{method: http.MethodPut, n: 1, statusCode: 429, retryAfter: "0"},
+			retry:        retry,
+			wantAttempts: 2,
+		},
+		{
+			name:         "delete 502 recovered",
+			fault:        faultHandler{method: http.MethodDelete, n: 1, statusCode: 502},
+			retry:        retry,
+			wantAttempts: 2,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := tt.fault
+			ts := newFaultTestServer(&f)
+			defer ts.Close()
+			c, _ := NewJSONServerClient(ts.URL+"/posts", &JSONServerClientOption{Retry: tt.retry})
+
+			id, err := c.Create(ctx, []byte(`{"name": "foo"}`))
+			require.NoError(t, err, "create failed")
+			switch tt.fault.method {
+			case http.MethodGet:
+				_, _, err = c.Read(ctx, id)
+			case http.MethodPut:
+				err = c.Update(ctx, id, []byte(`{"name": "bar"}`), "")
+			case http.MethodDelete:
+				err = c.Delete(ctx, id, "")
+			}
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantAttempts, atomic.LoadInt64(&f.attempts), "attempts")
+		})
+	}
+}
+
+func TestRetryPost(t *testing.T) {
+	retry := &RetryOption{
+		MaxAttempts: 3,
+		BaseBackoff: time.Millisecond,
+	}
+	ctx := context.Background()
+
+	// The connection reset after the request is sent might happen after the server has processed it.
+	// A new handler is used for each server, as the handler of the former one might still be running.
+	f := &faultHandler{method: http.MethodPost, n: 1}
+	ts := newFaultTestServer(f)
+	defer ts.Close()
+	c, _ := NewJSONServerClient(ts.URL+"/posts", &JSONServerClientOption{Retry: retry})
+	_, err := c.Create(ctx, []byte(`{"name": "foo"}`))
+	require.Error(t, err, "create with connection reset")
+	require.EqualValues(t, 1, atomic.LoadInt64(&f.attempts), "create with connection reset is not retried")
+
+	// 503 means the server hasn't processed the request.
+	f = &faultHandler{method: http.MethodPost, n: 1, statusCode: 503}
+	ts = newFaultTestServer(f)
+	defer ts.Close()
+	c, _ = NewJSONServerClient(ts.URL+"/posts", &JSONServerClientOption{Retry: retry})
+	_, err = c.Create(ctx, []byte(`{"name": "foo"}`))
+	require.NoError(t, err, "create with 503")
+	require.EqualValues(t, 2, atomic.LoadInt64(&f.attempts), "create with 503 is retried")
+
+	// 502 might be returned after the upstream has processed the request.
+	f = &faultHandler{method: http.MethodPost, n: 1, statusCode: 502}
+	ts = newFaultTestServer(f)
+	defer ts.Close()
+	c, _ = NewJSONServerClient(ts.URL+"/posts", &JSONServerClientOption{Retry: retry})
+	_, err = c.Create(ctx, []byte(`{"name": "foo"}`))
+	require.Error(t, err, "create with 502")
+	require.EqualValues(t, 1, atomic.LoadInt64(&f.attempts), "create with 502 is not retried")
+}
+
+func TestRetryCanceled(t *testing.T) {
+	f := faultHandler{method: http.MethodGet, n: 1, statusCode: 503, retryAfter: "3600"}
+	ts := newFaultTestServer(&f)
+	defer ts.Close()
+	c, _ := NewJSONServerClient(ts.URL+"/posts", &JSONServerClientOption{Retry: &RetryOption{MaxAttempts: 3}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, _, err := c.Read(ctx, "1")
+	require.ErrorIs(t, err, context.DeadlineExceeded, "waiting for Retry-After is interrupted by the context")
+}
+
+func TestRetryDelay(t *testing.T) {
+	opt := RetryOption{
+		BaseBackoff: time.Second,
+		MaxBackoff:  5 * time.Second,
+	}
+	require.Equal(t, time.Second, opt.retryDelay(1, nil))
+	require.Equal(t, 2*time.Second, opt.retryDelay(2, nil))
+	require.Equal(t, 4*time.Second, opt.retryDelay(3, nil))
+	require.Equal(t, 5*time.Second, opt.retryDelay(4, nil))
+	require.Equal(t, 5*time.Second, opt.retryDelay(100, nil))
+
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Retry-After", "3")
+	require.Equal(t, 3*time.Second, opt.retryDelay(1, resp), "Retry-After takes precedence")
+	resp.Header.Set("Retry-After", "3600")
+	require.Equal(t, 5*time.Second, opt.retryDelay(1, resp), "Retry-After is capped by the max backoff")
+	resp.Header.Set("Retry-After", "invalid")
+	require.Equal(t, time.Second, opt.retryDelay(1, resp), "invalid Retry-After is ignored")
+
+	d, ok := retryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	require.True(t, ok, "Retry-After of HTTP date")
+	require.InDelta(t, time.Minute, d, float64(2*time.Second))
+
+	opt.Jitter = true
+	for i := 0; i < 100; i++ {
+		d := opt.backoff(2)
+		require.GreaterOrEqual(t, d, time.Second)
+		require.LessOrEqual(t, d, 2*time.Second)
+	}
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// TransportOption configures the HTTP client used to talk to the server.
+type TransportOption struct {
+	// Timeout is the time limit of each request attempt, including connection, redirects and reading the response body.
+	// Zero means no timeout.
+	Timeout time.Duration
+	// TLS configures the TLS connection to the server.
+	TLS *TLSOption
+	// ProxyURL is the URL of the proxy. If empty, the proxy is determined by the environment variables (e.g. HTTPS_PROXY).
+	ProxyURL string
+}
+
+// TLSOption configures the TLS connection. The certificates and keys can be specified either as a file or as PEM
+// encoded content, but not both.
+type TLSOption struct {
+	// CAFile and CAPEM specify the CA certificates used to verify the server, in addition to the system ones.
+	CAFile string
+	CAPEM  string
+	// ClientCertFile/ClientCertPEM and ClientKeyFile/ClientKeyPEM specify the client certificate and its private key
+	// used for mutual TLS.
+	ClientCertFile string
+	ClientCertPEM  string
+	ClientKeyFile  string
+	ClientKeyPEM   string
+	// InsecureSkipVerify disables the verification of the server certificate.
+	InsecureSkipVerify bool
+}
+
+// newHTTPClient builds a dedicated HTTP client. A nil option results into the http.DefaultClient.
+func newHTTPClient(opt *TransportOption) (*http.Client, error) {
+	if opt == nil {
+		return http.DefaultClient, nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opt.ProxyURL != "" {
+		proxyURL, err := url.Parse(opt.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("parsing proxy URL: %v", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if opt.TLS != nil {
+		tlsConfig, err := newTLSConfig(*opt.TLS)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   opt.Timeout,
+	}, nil
+}
+
+func newTLSConfig(opt TLSOption) (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: opt.InsecureSkipVerify,
+	}
+
+	caPEM, err := fileOrPEM("CA certificate", opt.CAFile, opt.CAPEM)
+	if err != nil {
+		return nil, err
+	}
+	if caPEM != nil {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no valid CA certificate found")
+		}
+		config.RootCAs = pool
+	}
+
+	certPEM, err := fileOrPEM("client certificate", opt.ClientCertFile, opt.ClientCertPEM)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := fileOrPEM("client key", opt.ClientKeyFile, opt.ClientKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	if (certPEM == nil) != (keyPEM == nil) {
+		return nil, fmt.Errorf("the client certificate and the client key must be specified together")
+	}
+	if certPEM != nil {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// fileOrPEM returns the PEM content either read from the file, or the specified one. Nil is returned if neither
+// is specified.
+func fileOrPEM(name, file, pem string) ([]byte, error) {
+	if file != "" && pem != "" {
+		return nil, fmt.Errorf("only one of the %s file and PEM can be specified", name)
+	}
+	if file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", name, err)
+		}
+		return b, nil
+	}
+	if pem != "" {
+		return []byte(pem), nil
+	}
+	return nil, nil
+}
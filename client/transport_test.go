@@ -0,0 +1,187 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTLSTestServer(t *testing.T, clientCAs *x509.CertPool) (*httptest.Server, string) {
+	h := testHandler{
+		buf: map[string]map[string]interface{}{},
+	}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(h.Handle))
+	if clientCAs != nil {
+		ts.TLS = &tls.Config{
+			ClientAuth: tls.RequireAndVerifyClientCert,
+			ClientCAs:  clientCAs,
+		}
+	}
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	return ts, string(caPEM)
+}
+
+// newTestCertificate generates a self-signed client certificate, returns the PEM encoded certificate and key.
+func newTestCertificate(t *testing.T) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return cert,
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestTransportTLS(t *testing.T) {
+	ctx := context.Background()
+	ts, caPEM := newTLSTestServer(t, nil)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, []byte(caPEM), 0600))
+
+	cases := []struct {
+		name    string
+		tls     *TLSOption
+		wantErr bool
+	}{
+		{
+			name:    "untrusted server",
+			tls:     &TLSOption{},
+			wantErr: true,
+		},
+		{
+			name: "ca pem",
+			tls:  &TLSOption{CAPEM: caPEM},
+		},
+		{
+			name: "ca file",
+			tls:  &TLSOption{CAFile: caFile},
+		},
+		{
+			name: "insecure skip verify",
+			tls:  &TLSOption{InsecureSkipVerify: true},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewJSONServerClient(ts.URL+"/posts", &JSONServerClientOption{Transport: &TransportOption{TLS: tt.tls}})
+			require.NoError(t, err)
+			_, err = c.Create(ctx, []byte(`{"name": "foo"}`))
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestTransportMutualTLS(t *testing.T) {
+	ctx := context.Background()
+	clientCert, certPEM, keyPEM := newTestCertificate(t)
+	pool := x509.NewCertPool()
+	pool.AddCert(clientCert)
+	ts, caPEM := newTLSTestServer(t, pool)
+
+	c, err := NewJSONServerClient(ts.URL+"/posts", &JSONServerClientOption{Transport: &TransportOption{TLS: &TLSOption{CAPEM: caPEM}}})
+	require.NoError(t, err)
+	_, err = c.Create(ctx, []byte(`{"name": "foo"}`))
+	require.Error(t, err, "create without client certificate")
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, []byte(certPEM), 0600))
+	require.NoError(t, os.WriteFile(keyFile, []byte(keyPEM), 0600))
+	for _, opt := range []TLSOption{
+		{CAPEM: caPEM, ClientCertPEM: certPEM, ClientKeyPEM: keyPEM},
+		{CAPEM: caPEM, ClientCertFile: certFile, ClientKeyFile: keyFile},
+	} {
+		opt := opt
+		c, err := NewJSONServerClient(ts.URL+"/posts", &JSONServerClientOption{Transport: &TransportOption{TLS: &opt}})
+		require.NoError(t, err)
+		_, err = c.Create(ctx, []byte(`{"name": "foo"}`))
+		require.NoError(t, err, "create with client certificate")
+	}
+}
+
+func TestTransportInvalidTLSOption(t *testing.T) {
+	_, certPEM, keyPEM := newTestCertificate(t)
+	for _, opt := range []TLSOption{
+		{CAFile: "ca.pem", CAPEM: "pem"},
+		{CAPEM: "invalid"},
+		{CAFile: filepath.Join(t.TempDir(), "not-exist.pem")},
+		{ClientCertPEM: certPEM},
+		{ClientKeyPEM: keyPEM},
+		{ClientCertPEM: certPEM, ClientKeyPEM: "invalid"},
+	} {
+		opt := opt
+		_, err := NewJSONServerClient("https://localhost/posts", &JSONServerClientOption{Transport: &TransportOption{TLS: &opt}})
+		require.Error(t, err, "%#v", opt)
+	}
+}
+
+func TestTransportTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Second)
+	}))
+	defer ts.Close()
+	c, err := NewJSONServerClient(ts.URL+"/posts", &JSONServerClientOption{Transport: &TransportOption{Timeout: 50 * time.Millisecond}})
+	require.NoError(t, err)
+	_, _, err = c.Read(context.Background(), "1")
+	require.Error(t, err, "read exceeding the timeout")
+}
+
+func TestTransportProxy(t *testing.T) {
+	h := testHandler{
+		buf: map[string]map[string]interface{}{},
+	}
+	var proxied int64
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A forward proxy receives the request with the absolute URL of the target.
+		if r.URL.Host == "jsonserver.example" {
+			atomic.AddInt64(&proxied, 1)
+		}
+		h.Handle(w, r)
+	}))
+	defer proxy.Close()
+
+	c, err := NewJSONServerClient("http://jsonserver.example/posts", &JSONServerClientOption{Transport: &TransportOption{ProxyURL: proxy.URL}})
+	require.NoError(t, err)
+	ctx := context.Background()
+	id, err := c.Create(ctx, []byte(`{"name": "foo"}`))
+	require.NoError(t, err, "create via proxy")
+	_, _, err = c.Read(ctx, id)
+	require.NoError(t, err, "read via proxy")
+	require.EqualValues(t, 2, atomic.LoadInt64(&proxied), "requests sent via proxy")
+}
@@ -0,0 +1,197 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// WireLogSubsystem is the tflog subsystem, where the clients log the requests sent to the backend services, or the
+// files accessed by the FsClient. The summary of each request is logged at DEBUG, and its headers and bodies at TRACE.
+const WireLogSubsystem = "wire"
+
+// EnvWireLogLevel is the environment variable setting the level of the wire logs. It defaults to the level of the
+// provider logs, i.e. TF_LOG_PROVIDER.
+const EnvWireLogLevel = "TF_LOG_PROVIDER_DEMO_WIRE"
+
+// DefaultWireLogRedactFields are the JSON fields redacted from the wire logs by default.
+var DefaultWireLogRedactFields = []string{"password", "secret", "token", "api_key"}
+
+// defaultWireLogRedactHeaders are the headers always redacted from the wire logs, as they carry the credentials.
+var defaultWireLogRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Amz-Security-Token"}
+
+// redacted replaces the redacted values in the wire logs.
+const redacted = "<redacted>"
+
+// WireLogOption configures the redaction of the wire logs.
+type WireLogOption struct {
+	// RedactFields are the names of the JSON fields, matched case-insensitively at any depth, whose values are redacted
+	// from the logged bodies. Defaults to DefaultWireLogRedactFields if nil.
+	RedactFields []string
+	// RedactHeaders are the headers redacted from the logs, in addition to the ones carrying the credentials, e.g.
+	// the Authorization and the API key header.
+	RedactHeaders []string
+}
+
+// wireLogger logs the requests and the responses to the wire log subsystem, with the sensitive values redacted.
+type wireLogger struct {
+	fields  map[string]bool
+	headers map[string]bool
+	// redact further redacts the logged strings, e.g. the secrets of the authentication echoed by the server.
+	redact func(string) string
+}
+
+// newWireLogger returns the wire logger, which redacts the headers besides the ones of the option.
+func newWireLogger(opt *WireLogOption, headers ...string) *wireLogger {
+	if opt == nil {
+		opt = &WireLogOption{}
+	}
+	fields := opt.RedactFields
+	if fields == nil {
+		fields = DefaultWireLogRedactFields
+	}
+	l := &wireLogger{
+		fields:  map[string]bool{},
+		headers: map[string]bool{},
+		redact:  func(s string) string { return s },
+	}
+	for _, field := range fields {
+		l.fields[strings.ToLower(field)] = true
+	}
+	headers = append(append(headers, defaultWireLogRedactHeaders...), opt.RedactHeaders...)
+	for _, header := range headers {
+		if header != "" {
+			l.headers[http.CanonicalHeaderKey(header)] = true
+		}
+	}
+	return l
+}
+
+// withSubsystem returns the context holding the wire log subsystem, at the level set by EnvWireLogLevel.
+func withSubsystem(ctx context.Context) context.Context {
+	return tflog.NewSubsystem(ctx, WireLogSubsystem, tflog.WithLevelFromEnv(EnvWireLogLevel))
+}
+
+// logHTTP logs the HTTP request, whose final headers are in req if it is ever built, together with its response or
+// error.
+func (l *wireLogger) logHTTP(ctx context.Context, req *http.Request, method string, u url.URL, body []byte, resp *response, latency time.Duration, err error) {
+	ctx = withSubsystem(ctx)
+	fields := map[string]interface{}{
+		"method":     method,
+		"url":        l.redact(u.Redacted()),
+		"latency_ms": latency.Milliseconds(),
+	}
+	if resp != nil {
+		fields["status_code"] = resp.StatusCode
+	}
+	if err != nil {
+		fields["error"] = l.redact(err.Error())
+	}
+	tflog.SubsystemDebug(ctx, WireLogSubsystem, "HTTP request", fields)
+
+	details := map[string]interface{}{
+		"method": method,
+		"url":    fields["url"],
+	}
+	if req != nil {
+		details["request_headers"] = l.header(req.Header)
+	}
+	if body != nil {
+		details["request_body"] = l.body(body)
+	}
+	if resp != nil {
+		details["response_headers"] = l.header(resp.Header)
+		details["response_body"] = l.body(resp.Body)
+	}
+	tflog.SubsystemTrace(ctx, WireLogSubsystem, "HTTP request details", details)
+}
+
+// logFile logs the operation on the file, where in is the content written, and out is the content read, if any.
+func (l *wireLogger) logFile(ctx context.Context, operation, path string, in, out []byte, latency time.Duration, err error) {
+	ctx = withSubsystem(ctx)
+	fields := map[string]interface{}{
+		"operation":  operation,
+		"path":       path,
+		"latency_ms": latency.Milliseconds(),
+	}
+	if in != nil {
+		fields["bytes_written"] = len(in)
+	}
+	if out != nil {
+		fields["bytes_read"] = len(out)
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+	}
+	tflog.SubsystemDebug(ctx, WireLogSubsystem, "file operation", fields)
+	if in == nil && out == nil {
+		return
+	}
+
+	details := map[string]interface{}{
+		"operation": operation,
+		"path":      path,
+	}
+	if in != nil {
+		details["request_body"] = l.body(in)
+	}
+	if out != nil {
+		details["response_body"] = l.body(out)
+	}
+	tflog.SubsystemTrace(ctx, WireLogSubsystem, "file operation details", details)
+}
+
+// header returns the header to be logged, with the sensitive values redacted.
+func (l *wireLogger) header(h http.Header) map[string]string {
+	out := map[string]string{}
+	for k, v := range h {
+		if l.headers[http.CanonicalHeaderKey(k)] {
+			out[k] = redacted
+			continue
+		}
+		out[k] = l.redact(strings.Join(v, ", "))
+	}
+	return out
+}
+
+// body returns the body to be logged, where the values of the sensitive fields are redacted if it is a JSON document.
+func (l *wireLogger) body(b []byte) string {
+	var v interface{}
+	if len(l.fields) != 0 && unmarshalJSON(b, &v) == nil {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(l.redactJSON(v)); err == nil {
+			b = bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+		}
+	}
+	return l.redact(string(b))
+}
+
+// redactJSON redacts the values of the sensitive fields in the decoded JSON value.
+func (l *wireLogger) redactJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k := range v {
+			if l.fields[strings.ToLower(k)] {
+				v[k] = redacted
+				continue
+			}
+			v[k] = l.redactJSON(v[k])
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = l.redactJSON(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+)
+
+// wireLogs returns the logs of the wire log subsystem with the message.
+func wireLogs(t *testing.T, buf *bytes.Buffer, msg string) []map[string]interface{} {
+	entries, err := tflogtest.MultilineJSONDecode(buf)
+	require.NoError(t, err)
+	var logs []map[string]interface{}
+	for _, entry := range entries {
+		if entry["@module"] == "provider."+WireLogSubsystem && entry["@message"] == msg {
+			logs = append(logs, entry)
+		}
+	}
+	return logs
+}
+
+func TestWireLoggerBody(t *testing.T) {
+	l := newWireLogger(nil)
+	require.JSONEq(t,
+		`{"name": "foo", "Password": "<redacted>", "nested": [{"token": "<redacted>", "n": 1.50}]}`,
+		l.body([]byte(`{"name": "foo", "Password": "p", "nested": [{"token": {"a": 1}, "n": 1.50}]}`)),
+	)
+	require.Equal(t, "not json password", l.body([]byte("not json password")))
+
+	l = newWireLogger(&WireLogOption{RedactFields: []string{"name"}})
+	require.JSONEq(t, `{"name": "<redacted>", "password": "p"}`, l.body([]byte(`{"name": "foo", "password": "p"}`)))
+
+	l = newWireLogger(&WireLogOption{RedactFields: []string{}})
+	require.Equal(t, `{"password": "p"}`, l.body([]byte(`{"password": "p"}`)), "the body is kept as is")
+}
+
+func TestWireLoggerHeader(t *testing.T) {
+	l := newWireLogger(&WireLogOption{RedactHeaders: []string{"x-custom"}}, "X-Api-Key")
+	l.redact = func(s string) string { return s }
+	require.Equal(t, map[string]string{
+		"Authorization": redacted,
+		"X-Api-Key":     redacted,
+		"X-Custom":      redacted,
+		"Accept":        "a, b",
+	}, l.header(http.Header{
+		"Authorization": {"Bearer x"},
+		"X-Api-Key":     {"key"},
+		"X-Custom":      {"custom"},
+		"Accept":        {"a", "b"},
+	}))
+}
+
+func TestWireLogJSONServer(t *testing.T) {
+	h := testHandler{
+		buf: map[string]map[string]interface{}{},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(h.Handle))
+	defer ts.Close()
+	c, err := NewJSONServerClient(ts.URL+"/posts", &JSONServerClientOption{
+		Auth: &AuthOption{APIKeyHeader: "X-Api-Key", APIKey: "apikey"},
+	})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &buf)
+	_, err = c.Create(ctx, []byte(`{"name": "foo", "password": "secret-value"}`))
+	require.NoError(t, err)
+	_, _, err = c.Read(ctx, "2")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	logs := wireLogs(t, bytes.NewBuffer(buf.Bytes()), "HTTP request")
+	require.Len(t, logs, 2)
+	require.Equal(t, "POST", logs[0]["method"])
+	require.Equal(t, ts.URL+"/posts", logs[0]["url"])
+	require.EqualValues(t, http.StatusCreated, logs[0]["status_code"])
+	require.Contains(t, logs[0], "latency_ms")
+	require.Equal(t, "GET", logs[1]["method"])
+	require.EqualValues(t, http.StatusNotFound, logs[1]["status_code"])
+
+	details := wireLogs(t, bytes.NewBuffer(buf.Bytes()), "HTTP request details")
+	require.Len(t, details, 2)
+	require.Equal(t, redacted, details[0]["request_headers"].(map[string]interface{})["X-Api-Key"])
+	require.JSONEq(t, `{"name": "foo", "password": "<redacted>"}`, details[0]["request_body"].(string))
+	require.JSONEq(t, `{"id": 1, "name": "foo", "password": "<redacted>"}`, details[0]["response_body"].(string))
+	require.NotContains(t, buf.String(), "secret-value")
+	require.NotContains(t, buf.String(), "apikey")
+}
+
+func TestWireLogFs(t *testing.T) {
+	c, err := newFsClient(afero.NewMemMapFs(), "/tmp", &FsClientOption{Extension: ".json"})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &buf)
+	id, err := c.Create(ctx, []byte(`{"name": "foo", "secret": "secret-value"}`))
+	require.NoError(t, err)
+	_, _, err = c.Read(ctx, id)
+	require.NoError(t, err)
+	require.ErrorIs(t, c.Delete(ctx, "x", ""), ErrNotFound)
+
+	logs := wireLogs(t, bytes.NewBuffer(buf.Bytes()), "file operation")
+	require.Len(t, logs, 3)
+	require.Equal(t, "Create", logs[0]["operation"])
+	require.Equal(t, "/tmp/"+id+".json", logs[0]["path"])
+	require.EqualValues(t, 41, logs[0]["bytes_written"])
+	require.Equal(t, "Read", logs[1]["operation"])
+	require.EqualValues(t, 41, logs[1]["bytes_read"])
+	require.Equal(t, "Delete", logs[2]["operation"])
+	require.Equal(t, ErrNotFound.Error(), logs[2]["error"])
+
+	details := wireLogs(t, bytes.NewBuffer(buf.Bytes()), "file operation details")
+	require.Len(t, details, 2)
+	require.JSONEq(t, `{"name": "foo", "secret": "<redacted>"}`, details[1]["response_body"].(string))
+	require.NotContains(t, buf.String(), "secret-value")
+}
@@ -3,23 +3,71 @@ package acctest
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/magodo/terraform-provider-demo/client"
 )
 
 const (
-	EnvFsWorkdir = "DEMO_FS_WORKDIR"
-	EnvJsUrl     = "DEMO_JS_URL"
+	EnvFsWorkdir    = "DEMO_FS_WORKDIR"
+	EnvJsUrl        = "DEMO_JS_URL"
+	EnvLogStorePath = "DEMO_LOGSTORE_PATH"
+	EnvMemoryName   = "DEMO_MEMORY_NAME"
+	EnvGitWorkdir   = "DEMO_GIT_WORKDIR"
 )
 
+// backendEnvs are the environment variables selecting the backend.
+var backendEnvs = []string{EnvFsWorkdir, EnvJsUrl, EnvLogStorePath, EnvMemoryName, EnvGitWorkdir}
+
+// defaultMemoryName is the name of the in-memory store used when none of the environment variables is set.
+const defaultMemoryName = "acctest"
+
+// checkEnv checks that at most one of the environment variables selecting the backend is set. The in-memory backend
+// is used if none is set.
+func checkEnv() error {
+	var n int
+	var names []string
+	for _, env := range backendEnvs {
+		names = append(names, strconv.Quote(env))
+		if os.Getenv(env) != "" {
+			n++
+		}
+	}
+	if n > 1 {
+		return fmt.Errorf("Only one of the environment variables %s can be set", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// memoryName returns the name of the in-memory store, or empty if another backend is selected.
+func memoryName() string {
+	for _, env := range backendEnvs {
+		if env != EnvMemoryName && os.Getenv(env) != "" {
+			return ""
+		}
+	}
+	if name := os.Getenv(EnvMemoryName); name != "" {
+		return name
+	}
+	return defaultMemoryName
+}
+
 func buildClient() (client.Client, error) {
-	envFsWorkdir := os.Getenv(EnvFsWorkdir)
-	envJsUrl := os.Getenv(EnvJsUrl)
-	if (envFsWorkdir == "") == (envJsUrl == "") {
-		return nil, fmt.Errorf("One of either environment variable %q or %q has to be set", EnvFsWorkdir, EnvJsUrl)
+	if err := checkEnv(); err != nil {
+		return nil, err
+	}
+	if envFsWorkdir := os.Getenv(EnvFsWorkdir); envFsWorkdir != "" {
+		return client.NewFsClient(envFsWorkdir, nil)
+	}
+	if envLogStorePath := os.Getenv(EnvLogStorePath); envLogStorePath != "" {
+		return client.NewLogStoreClient(envLogStorePath, nil)
+	}
+	if envGitWorkdir := os.Getenv(EnvGitWorkdir); envGitWorkdir != "" {
+		return client.NewGitClient(envGitWorkdir, nil)
 	}
-	if envFsWorkdir != "" {
-		return client.NewFsClient(envFsWorkdir)
+	if name := memoryName(); name != "" {
+		return client.SharedMemoryClient(name), nil
 	}
-	return client.NewJSONServerClient(envJsUrl)
+	return client.NewJSONServerClient(os.Getenv(EnvJsUrl), nil)
 }
@@ -1,6 +1,8 @@
 package acctest
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"testing"
@@ -38,6 +40,33 @@ provider "demo" {
   }
 }
 `, envFsWorkdir)
+	}
+	if name := memoryName(); name != "" {
+		return tfconfig + fmt.Sprintf(`
+provider "demo" {
+  memory = {
+    name = "%s"
+  }
+}
+`, name)
+	}
+	if envLogStorePath := os.Getenv(EnvLogStorePath); envLogStorePath != "" {
+		return tfconfig + fmt.Sprintf(`
+provider "demo" {
+  logstore = {
+    path = "%s"
+  }
+}
+`, envLogStorePath)
+	}
+	if envGitWorkdir := os.Getenv(EnvGitWorkdir); envGitWorkdir != "" {
+		return tfconfig + fmt.Sprintf(`
+provider "demo" {
+  git = {
+    workdir = "%s"
+  }
+}
+`, envGitWorkdir)
 	}
 	return tfconfig + fmt.Sprintf(`
 provider "demo" {
@@ -49,10 +78,8 @@ provider "demo" {
 }
 
 func PreCheck(t *testing.T, customChecker func()) {
-	envFsWorkdir := os.Getenv(EnvFsWorkdir)
-	envJsUrl := os.Getenv(EnvJsUrl)
-	if (envFsWorkdir == "") == (envJsUrl == "") {
-		t.Fatalf("One of either environment variable %q or %q has to be set", EnvFsWorkdir, EnvJsUrl)
+	if err := checkEnv(); err != nil {
+		t.Fatal(err)
 	}
 	if customChecker != nil {
 		customChecker()
@@ -70,7 +97,7 @@ func IsDestroy(rt string) func(s *terraform.State) error {
 				continue
 			}
 
-			if label, err := c.Read(resource.Primary.ID); err != client.ErrNotFound {
+			if label, _, err := c.Read(context.Background(), resource.Primary.ID); !errors.Is(err, client.ErrNotFound) {
 				return fmt.Errorf("reading %s.%s: %v", resource.Type, label, err)
 			}
 		}
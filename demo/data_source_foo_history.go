@@ -0,0 +1,149 @@
+package demo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/magodo/terraform-provider-demo/client"
+)
+
+type dataSourceFooHistory struct {
+	client client.Client
+}
+
+type fooHistoryData struct {
+	ID       types.String      `tfsdk:"id"`
+	Versions []fooRevisionData `tfsdk:"versions"`
+}
+
+type fooRevisionData struct {
+	Version   types.String `tfsdk:"version"`
+	Timestamp types.String `tfsdk:"timestamp"`
+	Content   types.String `tfsdk:"content"`
+	Deleted   types.Bool   `tfsdk:"deleted"`
+}
+
+var _ datasource.DataSourceWithConfigure = &dataSourceFooHistory{}
+
+// Metadata implements datasource.DataSource.
+func (*dataSourceFooHistory) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_foo_history"
+}
+
+// Schema implements datasource.DataSource.
+func (*dataSourceFooHistory) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description:         "The prior versions of a Foo, which requires the backend to keep the history",
+		MarkdownDescription: "The prior versions of a `demo_foo`, which requires the backend to keep the history",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Description:         "The id of the Foo, which might have been deleted",
+				MarkdownDescription: "The id of the `demo_foo`, which might have been deleted",
+				Required:            true,
+			},
+			"versions": schema.ListNestedAttribute{
+				Description:         "The prior versions, the newest first",
+				MarkdownDescription: "The prior versions, the newest first",
+				Computed:            true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"version": schema.StringAttribute{
+							Description:         "The version, which identifies the version to restore",
+							MarkdownDescription: "The version, which identifies the version to restore",
+							Computed:            true,
+						},
+						"timestamp": schema.StringAttribute{
+							Description:         "When the version is superseded, in RFC3339 format",
+							MarkdownDescription: "When the version is superseded, in RFC3339 format",
+							Computed:            true,
+						},
+						"content": schema.StringAttribute{
+							Description:         "The JSON content of the version",
+							MarkdownDescription: "The JSON content of the version",
+							Computed:            true,
+						},
+						"deleted": schema.BoolAttribute{
+							Description:         "Whether the Foo has been deleted, and the version is kept in the trash",
+							MarkdownDescription: "Whether the `demo_foo` has been deleted, and the version is kept in the trash",
+							Computed:            true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d *dataSourceFooHistory) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	provider, ok := req.ProviderData.(*Provider)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Data Source Configure Type",
+			fmt.Sprintf("got: %T.", req.ProviderData),
+		)
+		return
+	}
+	c, err := provider.collection(fooCollection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to get the client of the collection",
+			fmt.Sprintf("Getting the client of the collection %q: %v", fooCollection, err),
+		)
+		return
+	}
+	d.client = c
+}
+
+// Read implements datasource.DataSource.
+func (d *dataSourceFooHistory) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var config fooHistoryData
+	diags := req.Config.Get(ctx, &config)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+	hc, ok := d.client.(client.HistoryClient)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Read failure",
+			"The configured backend doesn't keep the history of the resources",
+		)
+		return
+	}
+	revisions, err := hc.History(ctx, config.ID.ValueString())
+	if err != nil {
+		if errors.Is(err, client.ErrNotFound) {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("id"),
+				"Read failure",
+				fmt.Sprintf("No history is found for %q", config.ID.ValueString()),
+			)
+			return
+		}
+		addClientError(&resp.Diagnostics, "Read failure", "Reading the history", err)
+		return
+	}
+	state := fooHistoryData{
+		ID:       config.ID,
+		Versions: []fooRevisionData{},
+	}
+	for _, rev := range revisions {
+		state.Versions = append(state.Versions, fooRevisionData{
+			Version:   types.StringValue(rev.Version),
+			Timestamp: types.StringValue(rev.Timestamp.UTC().Format(time.RFC3339Nano)),
+			Content:   types.StringValue(string(rev.Content)),
+			Deleted:   types.BoolValue(rev.Deleted),
+		})
+	}
+	diags = resp.State.Set(ctx, state)
+	resp.Diagnostics.Append(diags...)
+}
@@ -0,0 +1,38 @@
+package demo
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/magodo/terraform-provider-demo/client"
+)
+
+// addClientError adds the error returned by the client to the diagnostics. The summary is tailored to the class of
+// the error, and falls back to the specified one for the unclassified errors. The detail describes the operation.
+func addClientError(diags *diag.Diagnostics, summary, detail string, err error) {
+	switch {
+	case errors.Is(err, client.ErrUnauthorized):
+		diags.AddError(
+			"Authentication failure",
+			fmt.Sprintf("%s: %v\n\nPlease check the credential configured in the provider.", detail, err),
+		)
+	case errors.Is(err, client.ErrForbidden):
+		diags.AddError(
+			"Permission denied",
+			fmt.Sprintf("%s: %v\n\nPlease check the permission of the credential configured in the provider.", detail, err),
+		)
+	case errors.Is(err, client.ErrInvalidRequest):
+		diags.AddError(
+			"Invalid request",
+			fmt.Sprintf("%s: %v\n\nThe backend rejected the request, please check the resource configuration.", detail, err),
+		)
+	case errors.Is(err, client.ErrConflict):
+		diags.AddError(
+			"Resource modified concurrently",
+			fmt.Sprintf("%s: %v\n\nThe resource has been modified outside of Terraform since it was last read, please refresh and retry.", detail, err),
+		)
+	default:
+		diags.AddError(summary, fmt.Sprintf("%s: %v", detail, err))
+	}
+}
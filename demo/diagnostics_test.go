@@ -0,0 +1,32 @@
+package demo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/magodo/terraform-provider-demo/client"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddClientError(t *testing.T) {
+	cases := []struct {
+		err         error
+		wantSummary string
+	}{
+		{err: &client.APIError{StatusCode: 401}, wantSummary: "Authentication failure"},
+		{err: &client.APIError{StatusCode: 403}, wantSummary: "Permission denied"},
+		{err: &client.APIError{StatusCode: 422}, wantSummary: "Invalid request"},
+		{err: fmt.Errorf("wrapped: %w", client.ErrConflict), wantSummary: "Resource modified concurrently"},
+		{err: &client.APIError{StatusCode: 500}, wantSummary: "Update failure"},
+		{err: errors.New("connection refused"), wantSummary: "Update failure"},
+	}
+	for _, tt := range cases {
+		var diags diag.Diagnostics
+		addClientError(&diags, "Update failure", "Sending update request", tt.err)
+		require.Len(t, diags, 1)
+		require.Equal(t, tt.wantSummary, diags[0].Summary(), "%v", tt.err)
+		require.Contains(t, diags[0].Detail(), "Sending update request: "+tt.err.Error())
+	}
+}
@@ -2,18 +2,36 @@ package demo
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/magodo/terraform-provider-demo/client"
 )
 
 type Provider struct {
 	client client.Client
+	// middlewares are the ones wrapping the client, which also wrap the clients of the collections.
+	middlewares []client.Middleware
+	// collections tells whether the backend keeps the resources of each type in its own collection.
+	collections bool
+
+	mu                sync.Mutex
+	collectionClients map[string]client.Client
 }
 
 var _ provider.Provider = &Provider{}
@@ -21,14 +39,179 @@ var _ provider.Provider = &Provider{}
 type providerData struct {
 	FileSystem types.Object `tfsdk:"filesystem"`
 	JSONServer types.Object `tfsdk:"jsonserver"`
+	LogStore   types.Object `tfsdk:"logstore"`
+	Memory     types.Object `tfsdk:"memory"`
+	REST       types.Object `tfsdk:"rest"`
+	Git        types.Object `tfsdk:"git"`
+	S3         types.Object `tfsdk:"s3"`
+	Middleware types.Object `tfsdk:"middleware"`
+	WireLog    types.Object `tfsdk:"wire_log"`
+}
+
+type middlewareData struct {
+	Logging types.Bool   `tfsdk:"logging"`
+	Timing  types.Bool   `tfsdk:"timing"`
+	Cache   types.Object `tfsdk:"cache"`
+}
+
+type cacheData struct {
+	PrefetchThreshold types.Int64 `tfsdk:"prefetch_threshold"`
+}
+
+type wireLogData struct {
+	RedactFields  types.List `tfsdk:"redact_fields"`
+	RedactHeaders types.List `tfsdk:"redact_headers"`
 }
 
 type filesystemData struct {
-	Workdir types.String `tfsdk:"workdir"`
+	Workdir          types.String `tfsdk:"workdir"`
+	LockTimeout      types.String `tfsdk:"lock_timeout"`
+	Extension        types.String `tfsdk:"extension"`
+	ShardLength      types.Int64  `tfsdk:"shard_length"`
+	TypeSubdirectory types.Bool   `tfsdk:"type_subdirectory"`
+	Pretty           types.Bool   `tfsdk:"pretty"`
+	FileMode         types.String `tfsdk:"file_mode"`
+	DirMode          types.String `tfsdk:"dir_mode"`
+	Compress         types.Bool   `tfsdk:"compress"`
+	HistoryLimit     types.Int64  `tfsdk:"history_limit"`
+	TrashRetention   types.String `tfsdk:"trash_retention"`
+	Encryption       types.Object `tfsdk:"encryption"`
+	RateLimit        types.Object `tfsdk:"rate_limit"`
+}
+
+type encryptionData struct {
+	KeyID        types.String `tfsdk:"key_id"`
+	Key          types.String `tfsdk:"key"`
+	KeyFile      types.String `tfsdk:"key_file"`
+	PreviousKeys types.Map    `tfsdk:"previous_keys"`
+}
+
+// EnvFsEncryptionKey is the environment variable of the base64 encoded encryption key, used when neither the key nor
+// the key file is specified.
+const EnvFsEncryptionKey = "DEMO_FS_ENCRYPTION_KEY"
+
+type logstoreData struct {
+	Path              types.String  `tfsdk:"path"`
+	LockTimeout       types.String  `tfsdk:"lock_timeout"`
+	CompactionRatio   types.Float64 `tfsdk:"compaction_ratio"`
+	CompactionMinSize types.Int64   `tfsdk:"compaction_min_size"`
+	RateLimit         types.Object  `tfsdk:"rate_limit"`
+}
+
+type gitData struct {
+	Workdir     types.String `tfsdk:"workdir"`
+	LockTimeout types.String `tfsdk:"lock_timeout"`
+	Extension   types.String `tfsdk:"extension"`
+	Pretty      types.Bool   `tfsdk:"pretty"`
+	AuthorName  types.String `tfsdk:"author_name"`
+	AuthorEmail types.String `tfsdk:"author_email"`
+	Branch      types.String `tfsdk:"branch"`
+	Command     types.String `tfsdk:"command"`
+	RateLimit   types.Object `tfsdk:"rate_limit"`
+}
+
+type s3Data struct {
+	Bucket            types.String `tfsdk:"bucket"`
+	Endpoint          types.String `tfsdk:"endpoint"`
+	Region            types.String `tfsdk:"region"`
+	Prefix            types.String `tfsdk:"prefix"`
+	PathStyle         types.Bool   `tfsdk:"path_style"`
+	AccessKeyID       types.String `tfsdk:"access_key_id"`
+	SecretAccessKey   types.String `tfsdk:"secret_access_key"`
+	SessionToken      types.String `tfsdk:"session_token"`
+	ConditionalWrites types.Bool   `tfsdk:"conditional_writes"`
+	Retry             types.Object `tfsdk:"retry"`
+	Timeout           types.String `tfsdk:"timeout"`
+	RateLimit         types.Object `tfsdk:"rate_limit"`
+}
+
+// The environment variables of the credential and the region of the s3 backend, used when they are not specified.
+const (
+	EnvAWSAccessKeyID     = "AWS_ACCESS_KEY_ID"
+	EnvAWSSecretAccessKey = "AWS_SECRET_ACCESS_KEY"
+	EnvAWSSessionToken    = "AWS_SESSION_TOKEN"
+	EnvAWSRegion          = "AWS_REGION"
+)
+
+type memoryData struct {
+	Name      types.String `tfsdk:"name"`
+	RateLimit types.Object `tfsdk:"rate_limit"`
 }
 
 type jsonserverData struct {
-	URL types.String `tfsdk:"url"`
+	URL       types.String `tfsdk:"url"`
+	BaseURL   types.String `tfsdk:"base_url"`
+	IDField   types.String `tfsdk:"id_field"`
+	RateLimit types.Object `tfsdk:"rate_limit"`
+}
+
+type restData struct {
+	URL            types.String `tfsdk:"url"`
+	Create         types.Object `tfsdk:"create"`
+	Read           types.Object `tfsdk:"read"`
+	Update         types.Object `tfsdk:"update"`
+	Patch          types.Object `tfsdk:"patch"`
+	Delete         types.Object `tfsdk:"delete"`
+	List           types.Object `tfsdk:"list"`
+	IDPath         types.String `tfsdk:"id_path"`
+	IDFromLocation types.Bool   `tfsdk:"id_from_location"`
+	ListItemsPath  types.String `tfsdk:"list_items_path"`
+	ListItemIDPath types.String `tfsdk:"list_item_id_path"`
+	RateLimit      types.Object `tfsdk:"rate_limit"`
+}
+
+type restRouteData struct {
+	Method      types.String `tfsdk:"method"`
+	Path        types.String `tfsdk:"path"`
+	StatusCodes types.List   `tfsdk:"status_codes"`
+}
+
+// httpData is the attributes shared by the HTTP based backends.
+type httpData struct {
+	Retry              types.Object `tfsdk:"retry"`
+	Timeout            types.String `tfsdk:"timeout"`
+	CAFile             types.String `tfsdk:"ca_file"`
+	CAPEM              types.String `tfsdk:"ca_pem"`
+	ClientCertFile     types.String `tfsdk:"client_cert_file"`
+	ClientCertPEM      types.String `tfsdk:"client_cert_pem"`
+	ClientKeyFile      types.String `tfsdk:"client_key_file"`
+	ClientKeyPEM       types.String `tfsdk:"client_key_pem"`
+	InsecureSkipVerify types.Bool   `tfsdk:"insecure_skip_verify"`
+	ProxyURL           types.String `tfsdk:"proxy_url"`
+	BearerToken        types.String `tfsdk:"bearer_token"`
+	BasicAuth          types.Object `tfsdk:"basic_auth"`
+	APIKey             types.Object `tfsdk:"api_key"`
+	Headers            types.Map    `tfsdk:"headers"`
+	CredentialHelper   types.Object `tfsdk:"credential_helper"`
+	Compress           types.Bool   `tfsdk:"compress"`
+}
+
+type basicAuthData struct {
+	Username types.String `tfsdk:"username"`
+	Password types.String `tfsdk:"password"`
+}
+
+type apiKeyData struct {
+	Header types.String `tfsdk:"header"`
+	Value  types.String `tfsdk:"value"`
+}
+
+type credentialHelperData struct {
+	Command types.String `tfsdk:"command"`
+	Args    types.List   `tfsdk:"args"`
+}
+
+type rateLimitData struct {
+	RequestsPerSecond types.Float64 `tfsdk:"requests_per_second"`
+	Burst             types.Int64   `tfsdk:"burst"`
+	MaxInFlight       types.Int64   `tfsdk:"max_in_flight"`
+}
+
+type retryData struct {
+	MaxAttempts types.Int64  `tfsdk:"max_attempts"`
+	BaseBackoff types.String `tfsdk:"base_backoff"`
+	MaxBackoff  types.String `tfsdk:"max_backoff"`
+	Jitter      types.Bool   `tfsdk:"jitter"`
 }
 
 func New() provider.Provider {
@@ -47,31 +230,568 @@ func (p *Provider) Schema(_ context.Context, req provider.SchemaRequest, resp *p
 			"filesystem": schema.SingleNestedAttribute{
 				Optional: true,
 				Attributes: map[string]schema.Attribute{
+					"rate_limit": rateLimitAttribute(),
 					"workdir": schema.StringAttribute{
 						Description:         "The directory to store the json files",
 						MarkdownDescription: "The directory to store the json files",
 						Required:            true,
 					},
+					"lock_timeout": schema.StringAttribute{
+						Description:         "The maximum time to wait for the lock of a resource, e.g. 30s. Defaults to no timeout",
+						MarkdownDescription: "The maximum time to wait for the lock of a resource, e.g. `30s`. Defaults to no timeout",
+						Optional:            true,
+					},
+					"extension": schema.StringAttribute{
+						Description:         "The file extension of the json files, e.g. .json. Defaults to no extension",
+						MarkdownDescription: "The file extension of the json files, e.g. `.json`. Defaults to no extension",
+						Optional:            true,
+					},
+					"shard_length": schema.Int64Attribute{
+						Description:         "The length of the id prefix, which is used as the subdirectory to store the json files. Defaults to no sharding",
+						MarkdownDescription: "The length of the id prefix, which is used as the subdirectory to store the json files. Defaults to no sharding",
+						Optional:            true,
+					},
+					"type_subdirectory": schema.BoolAttribute{
+						Description:         "Whether to store the json files of each resource type under the subdirectory named by the type, e.g. <workdir>/foo for the demo_foo resources. The json files written directly under the workdir are seen as the demo_foo resources, and moved under its subdirectory once written",
+						MarkdownDescription: "Whether to store the json files of each resource type under the subdirectory named by the type, e.g. `<workdir>/foo` for the `demo_foo` resources. The json files written directly under the workdir are seen as the `demo_foo` resources, and moved under its subdirectory once written",
+						Optional:            true,
+					},
+					"pretty": schema.BoolAttribute{
+						Description:         "Whether to write the json files as the canonical pretty-printed JSON, with the keys sorted",
+						MarkdownDescription: "Whether to write the json files as the canonical pretty-printed JSON, with the keys sorted",
+						Optional:            true,
+					},
+					"file_mode": schema.StringAttribute{
+						Description:         "The permission of the json files in octal, e.g. 0600. Defaults to 0644",
+						MarkdownDescription: "The permission of the json files in octal, e.g. `0600`. Defaults to `0644`",
+						Optional:            true,
+					},
+					"dir_mode": schema.StringAttribute{
+						Description:         "The permission of the directories in octal, e.g. 0700. Defaults to 0755",
+						MarkdownDescription: "The permission of the directories in octal, e.g. `0700`. Defaults to `0755`",
+						Optional:            true,
+					},
+					"compress": schema.BoolAttribute{
+						Description:         "Whether to compress the json files by gzip. The existing json files are read no matter they are compressed or not",
+						MarkdownDescription: "Whether to compress the json files by gzip. The existing json files are read no matter they are compressed or not",
+						Optional:            true,
+					},
+					"history_limit": schema.Int64Attribute{
+						Description:         "The number of the prior versions kept for each resource. Defaults to no history",
+						MarkdownDescription: "The number of the prior versions kept for each resource. Defaults to no history",
+						Optional:            true,
+					},
+					"trash_retention": schema.StringAttribute{
+						Description:         "How long the deleted resources are kept in the trash together with their history, e.g. 720h. Defaults to deleting permanently",
+						MarkdownDescription: "How long the deleted resources are kept in the trash together with their history, e.g. `720h`. Defaults to deleting permanently",
+						Optional:            true,
+					},
+					"encryption": schema.SingleNestedAttribute{
+						Optional: true,
+						Attributes: map[string]schema.Attribute{
+							"key_id": schema.StringAttribute{
+								Description:         "The id of the key, which is recorded in the encrypted json files",
+								MarkdownDescription: "The id of the key, which is recorded in the encrypted json files",
+								Required:            true,
+							},
+							"key": schema.StringAttribute{
+								Description:         "The base64 encoded 32 bytes AES-256 key. Conflicts with key_file. Defaults to the DEMO_FS_ENCRYPTION_KEY environment variable",
+								MarkdownDescription: "The base64 encoded 32 bytes AES-256 key. Conflicts with `key_file`. Defaults to the `DEMO_FS_ENCRYPTION_KEY` environment variable",
+								Optional:            true,
+								Sensitive:           true,
+							},
+							"key_file": schema.StringAttribute{
+								Description:         "The path to the file containing the base64 encoded key. Conflicts with key",
+								MarkdownDescription: "The path to the file containing the base64 encoded key. Conflicts with `key`",
+								Optional:            true,
+							},
+							"previous_keys": schema.MapAttribute{
+								ElementType:         types.StringType,
+								Description:         "The base64 encoded keys by their ids, which are only used to decrypt the json files encrypted before the key rotation",
+								MarkdownDescription: "The base64 encoded keys by their ids, which are only used to decrypt the json files encrypted before the key rotation",
+								Optional:            true,
+								Sensitive:           true,
+							},
+						},
+						Description:         "The encryption at rest of the json files, by AES-256-GCM",
+						MarkdownDescription: "The encryption at rest of the json files, by AES-256-GCM",
+					},
 				},
 				Description:         "Using the filesystem as the backend service",
 				MarkdownDescription: "Using the filesystem as the backend service",
 			},
 			"jsonserver": schema.SingleNestedAttribute{
+				Optional: true,
+				Attributes: withHTTPAttributes(map[string]schema.Attribute{
+					"rate_limit": rateLimitAttribute(),
+					"url": schema.StringAttribute{
+						Description:         "The URL to the collection of the json-server storing all the resources. Conflicts with base_url",
+						MarkdownDescription: "The URL to the collection of the json-server storing all the resources. Conflicts with `base_url`",
+						Optional:            true,
+					},
+					"base_url": schema.StringAttribute{
+						Description:         "The base URL to the json-server, where the resources of each type are stored in the collection named by the type, e.g. <base_url>/foo for the demo_foo resources. Conflicts with url",
+						MarkdownDescription: "The base URL to the json-server, where the resources of each type are stored in the collection named by the type, e.g. `<base_url>/foo` for the `demo_foo` resources. Conflicts with `url`",
+						Optional:            true,
+					},
+					"id_field": schema.StringAttribute{
+						Description:         "The name of the id field of the resources, whose value is either a number (json-server v0) or a string (json-server v1). Defaults to id",
+						MarkdownDescription: "The name of the id field of the resources, whose value is either a number (json-server v0) or a string (json-server v1). Defaults to `id`",
+						Optional:            true,
+					},
+				}),
+				Description:         "Using the json-server as the backend service",
+				MarkdownDescription: "Using the [json-server](https://github.com/typicode/json-server) as the backend service",
+			},
+			"logstore": schema.SingleNestedAttribute{
+				Optional: true,
+				Attributes: map[string]schema.Attribute{
+					"rate_limit": rateLimitAttribute(),
+					"path": schema.StringAttribute{
+						Description:         "The path to the log file storing all the json objects",
+						MarkdownDescription: "The path to the log file storing all the json objects",
+						Required:            true,
+					},
+					"lock_timeout": schema.StringAttribute{
+						Description:         "The maximum time to wait for the lock of the log file, e.g. 30s. Defaults to no timeout",
+						MarkdownDescription: "The maximum time to wait for the lock of the log file, e.g. `30s`. Defaults to no timeout",
+						Optional:            true,
+					},
+					"compaction_ratio": schema.Float64Attribute{
+						Description:         "The ratio of the stale records in the log file, above which the log file is compacted on open or after a write. Defaults to 0.5. Negative value disables the automatic compaction",
+						MarkdownDescription: "The ratio of the stale records in the log file, above which the log file is compacted on open or after a write. Defaults to `0.5`. Negative value disables the automatic compaction",
+						Optional:            true,
+					},
+					"compaction_min_size": schema.Int64Attribute{
+						Description:         "The minimum size in bytes of the log file to be compacted automatically. Defaults to 1MiB",
+						MarkdownDescription: "The minimum size in bytes of the log file to be compacted automatically. Defaults to 1MiB",
+						Optional:            true,
+					},
+				},
+				Description:         "Using a single append-only log file as the backend service",
+				MarkdownDescription: "Using a single append-only log file as the backend service",
+			},
+			"memory": schema.SingleNestedAttribute{
 				Optional: true,
 				Attributes: map[string]schema.Attribute{
+					"rate_limit": rateLimitAttribute(),
+					"name": schema.StringAttribute{
+						Description:         "The name of the in-memory store, which is shared by the providers of the same name in the same process. Defaults to default",
+						MarkdownDescription: "The name of the in-memory store, which is shared by the providers of the same name in the same process. Defaults to `default`",
+						Optional:            true,
+					},
+				},
+				Description:         "Using the memory as the backend service, which is mainly for testing",
+				MarkdownDescription: "Using the memory as the backend service, which is mainly for testing",
+			},
+			"rest": schema.SingleNestedAttribute{
+				Optional: true,
+				Attributes: withHTTPAttributes(map[string]schema.Attribute{
+					"rate_limit": rateLimitAttribute(),
 					"url": schema.StringAttribute{
-						Description:         "The URL to the json-server",
-						MarkdownDescription: "The URL to the json-server",
+						Description:         "The base URL of the API, which the route paths are relative to",
+						MarkdownDescription: "The base URL of the API, which the route paths are relative to",
 						Required:            true,
 					},
+					"create": restRouteAttribute("creating a resource", "POST to the base URL, expecting 200 or 201"),
+					"read":   restRouteAttribute("reading a resource", "GET on {id}, expecting 200"),
+					"update": restRouteAttribute("replacing a resource", "PUT on {id}, expecting 200 or 204"),
+					"patch":  restRouteAttribute("sending the JSON merge patch to a resource", "applying the patch to the read resource, then sending it by the update route"),
+					"delete": restRouteAttribute("deleting a resource", "DELETE on {id}, expecting 200 or 204"),
+					"list":   restRouteAttribute("listing the resources", "GET on the base URL, expecting 200"),
+					"id_path": schema.StringAttribute{
+						Description:         "The JSON path of the id in the response of the create route, e.g. $.data.id. Defaults to $.id",
+						MarkdownDescription: "The JSON path of the id in the response of the create route, e.g. `$.data.id`. Defaults to `$.id`",
+						Optional:            true,
+					},
+					"id_from_location": schema.BoolAttribute{
+						Description:         "Whether to take the id from the last path segment of the Location header of the response of the create route, instead of from its body. Defaults to false",
+						MarkdownDescription: "Whether to take the id from the last path segment of the `Location` header of the response of the create route, instead of from its body. Defaults to `false`",
+						Optional:            true,
+					},
+					"list_items_path": schema.StringAttribute{
+						Description:         "The JSON path of the array of the resources in the response of the list route, e.g. $.items. Defaults to $",
+						MarkdownDescription: "The JSON path of the array of the resources in the response of the list route, e.g. `$.items`. Defaults to `$`",
+						Optional:            true,
+					},
+					"list_item_id_path": schema.StringAttribute{
+						Description:         "The JSON path of the id in each listed resource. Defaults to $.id",
+						MarkdownDescription: "The JSON path of the id in each listed resource. Defaults to `$.id`",
+						Optional:            true,
+					},
+				}),
+				Description:         "Using a generic REST API as the backend service, whose routes are configurable",
+				MarkdownDescription: "Using a generic REST API as the backend service, whose routes are configurable",
+			},
+			"git": schema.SingleNestedAttribute{
+				Optional: true,
+				Attributes: map[string]schema.Attribute{
+					"rate_limit": rateLimitAttribute(),
+					"workdir": schema.StringAttribute{
+						Description:         "The git working tree to store the json files, which is initialized if not a git repository",
+						MarkdownDescription: "The git working tree to store the json files, which is initialized if not a git repository",
+						Required:            true,
+					},
+					"lock_timeout": schema.StringAttribute{
+						Description:         "The maximum time to wait for a lock, e.g. 30s. Defaults to no timeout",
+						MarkdownDescription: "The maximum time to wait for a lock, e.g. `30s`. Defaults to no timeout",
+						Optional:            true,
+					},
+					"extension": schema.StringAttribute{
+						Description:         "The file extension of the json files, e.g. .json. Defaults to no extension",
+						MarkdownDescription: "The file extension of the json files, e.g. `.json`. Defaults to no extension",
+						Optional:            true,
+					},
+					"pretty": schema.BoolAttribute{
+						Description:         "Whether to write the json files as the canonical pretty-printed JSON, which makes the diffs between the commits readable. Defaults to false",
+						MarkdownDescription: "Whether to write the json files as the canonical pretty-printed JSON, which makes the diffs between the commits readable. Defaults to `false`",
+						Optional:            true,
+					},
+					"author_name": schema.StringAttribute{
+						Description:         "The name of the author of the commits. Defaults to terraform-provider-demo",
+						MarkdownDescription: "The name of the author of the commits. Defaults to `terraform-provider-demo`",
+						Optional:            true,
+					},
+					"author_email": schema.StringAttribute{
+						Description:         "The email of the author of the commits. Defaults to terraform-provider-demo@localhost",
+						MarkdownDescription: "The email of the author of the commits. Defaults to `terraform-provider-demo@localhost`",
+						Optional:            true,
+					},
+					"branch": schema.StringAttribute{
+						Description:         "The branch to commit to, which is checked out, or created from the current HEAD if not exists. Defaults to the current branch",
+						MarkdownDescription: "The branch to commit to, which is checked out, or created from the current `HEAD` if not exists. Defaults to the current branch",
+						Optional:            true,
+					},
+					"command": schema.StringAttribute{
+						Description:         "The path to the git command. Defaults to git found in the PATH",
+						MarkdownDescription: "The path to the git command. Defaults to `git` found in the `PATH`",
+						Optional:            true,
+					},
 				},
-				Description:         "Using the json-server as the backend service",
-				MarkdownDescription: "Using the [json-server](https://github.com/typicode/json-server) as the backend service",
+				Description:         "Using a git working tree as the backend service, where each change of a json file is committed",
+				MarkdownDescription: "Using a git working tree as the backend service, where each change of a json file is committed",
+			},
+			"s3": schema.SingleNestedAttribute{
+				Optional: true,
+				Attributes: map[string]schema.Attribute{
+					"rate_limit": rateLimitAttribute(),
+					"bucket": schema.StringAttribute{
+						Description:         "The name of the bucket",
+						MarkdownDescription: "The name of the bucket",
+						Required:            true,
+					},
+					"endpoint": schema.StringAttribute{
+						Description:         "The URL of the S3 compatible service, e.g. http://localhost:9000. Defaults to the AWS S3 endpoint of the region",
+						MarkdownDescription: "The URL of the S3 compatible service, e.g. `http://localhost:9000`. Defaults to the AWS S3 endpoint of the region",
+						Optional:            true,
+					},
+					"region": schema.StringAttribute{
+						Description:         "The region of the bucket. Defaults to the environment variable AWS_REGION, or us-east-1",
+						MarkdownDescription: "The region of the bucket. Defaults to the environment variable `AWS_REGION`, or `us-east-1`",
+						Optional:            true,
+					},
+					"prefix": schema.StringAttribute{
+						Description:         "The prefix of the object keys, e.g. demo/. Defaults to no prefix",
+						MarkdownDescription: "The prefix of the object keys, e.g. `demo/`. Defaults to no prefix",
+						Optional:            true,
+					},
+					"path_style": schema.BoolAttribute{
+						Description:         "Whether to address the bucket in the path of the URL, instead of in the host name, which is usually required by the S3 compatible services. Defaults to false",
+						MarkdownDescription: "Whether to address the bucket in the path of the URL, instead of in the host name, which is usually required by the S3 compatible services. Defaults to `false`",
+						Optional:            true,
+					},
+					"access_key_id": schema.StringAttribute{
+						Description:         "The access key ID. Defaults to the environment variable AWS_ACCESS_KEY_ID. The requests are sent anonymously if no credential is specified",
+						MarkdownDescription: "The access key ID. Defaults to the environment variable `AWS_ACCESS_KEY_ID`. The requests are sent anonymously if no credential is specified",
+						Optional:            true,
+					},
+					"secret_access_key": schema.StringAttribute{
+						Description:         "The secret access key. Defaults to the environment variable AWS_SECRET_ACCESS_KEY",
+						MarkdownDescription: "The secret access key. Defaults to the environment variable `AWS_SECRET_ACCESS_KEY`",
+						Optional:            true,
+						Sensitive:           true,
+					},
+					"session_token": schema.StringAttribute{
+						Description:         "The session token of the temporary credential. Defaults to the environment variable AWS_SESSION_TOKEN",
+						MarkdownDescription: "The session token of the temporary credential. Defaults to the environment variable `AWS_SESSION_TOKEN`",
+						Optional:            true,
+						Sensitive:           true,
+					},
+					"conditional_writes": schema.BoolAttribute{
+						Description:         "Whether to make the writes conditional on the versions by the If-Match and If-None-Match headers, which is not supported by all the S3 compatible services. Otherwise, the versions are checked by the reads ahead of the writes. Defaults to false",
+						MarkdownDescription: "Whether to make the writes conditional on the versions by the `If-Match` and `If-None-Match` headers, which is not supported by all the S3 compatible services. Otherwise, the versions are checked by the reads ahead of the writes. Defaults to `false`",
+						Optional:            true,
+					},
+					"retry":   httpAttributes()["retry"],
+					"timeout": httpAttributes()["timeout"],
+				},
+				Description:         "Using an S3 compatible bucket as the backend service",
+				MarkdownDescription: "Using an S3 compatible bucket as the backend service",
+			},
+			"middleware": schema.SingleNestedAttribute{
+				Optional: true,
+				Attributes: map[string]schema.Attribute{
+					"logging": schema.BoolAttribute{
+						Description:         "Whether to log each operation of the backend service, together with its error, at the debug level. Defaults to false",
+						MarkdownDescription: "Whether to log each operation of the backend service, together with its error, at the `DEBUG` level. Defaults to `false`",
+						Optional:            true,
+					},
+					"timing": schema.BoolAttribute{
+						Description:         "Whether to log the duration of each operation of the backend service at the debug level. Defaults to false",
+						MarkdownDescription: "Whether to log the duration of each operation of the backend service at the `DEBUG` level. Defaults to `false`",
+						Optional:            true,
+					},
+					"cache": schema.SingleNestedAttribute{
+						Optional: true,
+						Attributes: map[string]schema.Attribute{
+							"prefetch_threshold": schema.Int64Attribute{
+								Description:         "The number of the reads missing the cache, after which all the resources are loaded into the cache by a single list, at most once. The jsonserver and rest backends don't list the versions, so that their resources are still read one by one. Defaults to 0, which means no prefetch",
+								MarkdownDescription: "The number of the reads missing the cache, after which all the resources are loaded into the cache by a single list, at most once. The `jsonserver` and `rest` backends don't list the versions, so that their resources are still read one by one. Defaults to `0`, which means no prefetch",
+								Optional:            true,
+							},
+						},
+						Description:         "The read-through cache of the resources for the run of the provider, which coalesces the concurrent reads of the same resource, and is invalidated by the writes",
+						MarkdownDescription: "The read-through cache of the resources for the run of the provider, which coalesces the concurrent reads of the same resource, and is invalidated by the writes",
+					},
+				},
+				Description:         "The middlewares wrapping the operations of the backend service",
+				MarkdownDescription: "The middlewares wrapping the operations of the backend service",
+			},
+			"wire_log": schema.SingleNestedAttribute{
+				Optional: true,
+				Attributes: map[string]schema.Attribute{
+					"redact_fields": schema.ListAttribute{
+						ElementType:         types.StringType,
+						Description:         fmt.Sprintf("The names of the JSON fields, matched case-insensitively at any depth, whose values are redacted from the logged bodies. Defaults to %s", strings.Join(client.DefaultWireLogRedactFields, ", ")),
+						MarkdownDescription: fmt.Sprintf("The names of the JSON fields, matched case-insensitively at any depth, whose values are redacted from the logged bodies. Defaults to `%s`", strings.Join(client.DefaultWireLogRedactFields, "`, `")),
+						Optional:            true,
+					},
+					"redact_headers": schema.ListAttribute{
+						ElementType:         types.StringType,
+						Description:         "The headers redacted from the logs, in addition to the ones carrying the credentials, e.g. Authorization and the API key header",
+						MarkdownDescription: "The headers redacted from the logs, in addition to the ones carrying the credentials, e.g. `Authorization` and the API key header",
+						Optional:            true,
+					},
+				},
+				Description:         fmt.Sprintf("The redaction of the requests sent to the backend service, which are logged by the filesystem, jsonserver, rest, git and s3 backends to the %q subsystem, i.e. the summaries at the debug level and the headers and bodies at the trace level. The level can be set by %s", client.WireLogSubsystem, client.EnvWireLogLevel),
+				MarkdownDescription: fmt.Sprintf("The redaction of the requests sent to the backend service, which are logged by the `filesystem`, `jsonserver`, `rest`, `git` and `s3` backends to the `%s` subsystem, i.e. the summaries at the `DEBUG` level and the headers and bodies at the `TRACE` level. The level can be set by `%s`", client.WireLogSubsystem, client.EnvWireLogLevel),
 			},
 		},
 	}
 }
 
+// httpAttributes returns the attributes shared by the HTTP based backends.
+func httpAttributes() map[string]schema.Attribute {
+	return map[string]schema.Attribute{
+		"retry": schema.SingleNestedAttribute{
+			Optional: true,
+			Attributes: map[string]schema.Attribute{
+				"max_attempts": schema.Int64Attribute{
+					Description:         "The maximum number of attempts of a request, including the first one. Defaults to 4",
+					MarkdownDescription: "The maximum number of attempts of a request, including the first one. Defaults to `4`",
+					Optional:            true,
+				},
+				"base_backoff": schema.StringAttribute{
+					Description:         "The backoff before the first retry, which is doubled for each following retry. Defaults to 500ms",
+					MarkdownDescription: "The backoff before the first retry, which is doubled for each following retry. Defaults to `500ms`",
+					Optional:            true,
+				},
+				"max_backoff": schema.StringAttribute{
+					Description:         "The maximum backoff between retries, which also caps the delay asked by the Retry-After header. Defaults to 30s",
+					MarkdownDescription: "The maximum backoff between retries, which also caps the delay asked by the `Retry-After` header. Defaults to `30s`",
+					Optional:            true,
+				},
+				"jitter": schema.BoolAttribute{
+					Description:         "Whether to randomize the backoff. Defaults to true",
+					MarkdownDescription: "Whether to randomize the backoff. Defaults to `true`",
+					Optional:            true,
+				},
+			},
+			Description:         "Retry the idempotent requests on connection errors, 429, 502, 503 and 504, honoring the Retry-After header. The create requests are only retried when they are known not to be processed by the server",
+			MarkdownDescription: "Retry the idempotent requests on connection errors, `429`, `502`, `503` and `504`, honoring the `Retry-After` header. The create requests are only retried when they are known not to be processed by the server",
+		},
+		"timeout": schema.StringAttribute{
+			Description:         "The timeout of each request, e.g. 30s. Defaults to no timeout",
+			MarkdownDescription: "The timeout of each request, e.g. `30s`. Defaults to no timeout",
+			Optional:            true,
+		},
+		"ca_file": schema.StringAttribute{
+			Description:         "The path to the PEM encoded CA certificates used to verify the server, in addition to the system ones. Conflicts with ca_pem",
+			MarkdownDescription: "The path to the PEM encoded CA certificates used to verify the server, in addition to the system ones. Conflicts with `ca_pem`",
+			Optional:            true,
+		},
+		"ca_pem": schema.StringAttribute{
+			Description:         "The PEM encoded CA certificates used to verify the server, in addition to the system ones. Conflicts with ca_file",
+			MarkdownDescription: "The PEM encoded CA certificates used to verify the server, in addition to the system ones. Conflicts with `ca_file`",
+			Optional:            true,
+		},
+		"client_cert_file": schema.StringAttribute{
+			Description:         "The path to the PEM encoded client certificate for mutual TLS. Conflicts with client_cert_pem",
+			MarkdownDescription: "The path to the PEM encoded client certificate for mutual TLS. Conflicts with `client_cert_pem`",
+			Optional:            true,
+		},
+		"client_cert_pem": schema.StringAttribute{
+			Description:         "The PEM encoded client certificate for mutual TLS. Conflicts with client_cert_file",
+			MarkdownDescription: "The PEM encoded client certificate for mutual TLS. Conflicts with `client_cert_file`",
+			Optional:            true,
+		},
+		"client_key_file": schema.StringAttribute{
+			Description:         "The path to the PEM encoded private key of the client certificate. Conflicts with client_key_pem",
+			MarkdownDescription: "The path to the PEM encoded private key of the client certificate. Conflicts with `client_key_pem`",
+			Optional:            true,
+		},
+		"client_key_pem": schema.StringAttribute{
+			Description:         "The PEM encoded private key of the client certificate. Conflicts with client_key_file",
+			MarkdownDescription: "The PEM encoded private key of the client certificate. Conflicts with `client_key_file`",
+			Optional:            true,
+			Sensitive:           true,
+		},
+		"insecure_skip_verify": schema.BoolAttribute{
+			Description:         "Whether to skip the verification of the server certificate. Defaults to false",
+			MarkdownDescription: "Whether to skip the verification of the server certificate. Defaults to `false`",
+			Optional:            true,
+		},
+		"proxy_url": schema.StringAttribute{
+			Description:         "The URL of the proxy. Defaults to the one determined by the environment variables HTTP_PROXY, HTTPS_PROXY and NO_PROXY",
+			MarkdownDescription: "The URL of the proxy. Defaults to the one determined by the environment variables `HTTP_PROXY`, `HTTPS_PROXY` and `NO_PROXY`",
+			Optional:            true,
+		},
+		"bearer_token": schema.StringAttribute{
+			Description:         "The bearer token sent in the Authorization header. Conflicts with basic_auth, api_key and credential_helper",
+			MarkdownDescription: "The bearer token sent in the `Authorization` header. Conflicts with `basic_auth`, `api_key` and `credential_helper`",
+			Optional:            true,
+			Sensitive:           true,
+		},
+		"basic_auth": schema.SingleNestedAttribute{
+			Optional: true,
+			Attributes: map[string]schema.Attribute{
+				"username": schema.StringAttribute{
+					Description:         "The username",
+					MarkdownDescription: "The username",
+					Required:            true,
+				},
+				"password": schema.StringAttribute{
+					Description:         "The password",
+					MarkdownDescription: "The password",
+					Required:            true,
+					Sensitive:           true,
+				},
+			},
+			Description:         "The HTTP basic authentication. Conflicts with bearer_token, api_key and credential_helper",
+			MarkdownDescription: "The HTTP basic authentication. Conflicts with `bearer_token`, `api_key` and `credential_helper`",
+		},
+		"api_key": schema.SingleNestedAttribute{
+			Optional: true,
+			Attributes: map[string]schema.Attribute{
+				"header": schema.StringAttribute{
+					Description:         "The name of the header carrying the API key, e.g. X-API-Key",
+					MarkdownDescription: "The name of the header carrying the API key, e.g. `X-API-Key`",
+					Required:            true,
+				},
+				"value": schema.StringAttribute{
+					Description:         "The API key",
+					MarkdownDescription: "The API key",
+					Required:            true,
+					Sensitive:           true,
+				},
+			},
+			Description:         "The API key sent in a header. Conflicts with bearer_token, basic_auth and credential_helper",
+			MarkdownDescription: "The API key sent in a header. Conflicts with `bearer_token`, `basic_auth` and `credential_helper`",
+		},
+		"compress": schema.BoolAttribute{
+			Description:         "Whether to compress the request bodies by gzip, and ask for the gzip compressed responses",
+			MarkdownDescription: "Whether to compress the request bodies by gzip (`Content-Encoding: gzip`), and ask for the gzip compressed responses (`Accept-Encoding: gzip`)",
+			Optional:            true,
+		},
+		"headers": schema.MapAttribute{
+			ElementType:         types.StringType,
+			Description:         "The static headers sent along with each request. Their values are not redacted from the errors, the sensitive ones can be redacted from the wire logs by wire_log.redact_headers",
+			MarkdownDescription: "The static headers sent along with each request. Their values are not redacted from the errors, the sensitive ones can be redacted from the wire logs by `wire_log.redact_headers`",
+			Optional:            true,
+			Sensitive:           true,
+		},
+		"credential_helper": schema.SingleNestedAttribute{
+			Optional: true,
+			Attributes: map[string]schema.Attribute{
+				"command": schema.StringAttribute{
+					Description:         "The command to run",
+					MarkdownDescription: "The command to run",
+					Required:            true,
+				},
+				"args": schema.ListAttribute{
+					ElementType:         types.StringType,
+					Description:         "The arguments of the command",
+					MarkdownDescription: "The arguments of the command",
+					Optional:            true,
+				},
+			},
+			Description:         "The external command whose stdout supplies the bearer token. It is run again when the server responds 401. Conflicts with bearer_token, basic_auth and api_key",
+			MarkdownDescription: "The external command whose stdout supplies the bearer token. It is run again when the server responds `401`. Conflicts with `bearer_token`, `basic_auth` and `api_key`",
+		},
+	}
+}
+
+// restRouteAttribute returns the attribute of the route for the operation of the rest backend.
+func restRouteAttribute(operation, defaults string) schema.SingleNestedAttribute {
+	return schema.SingleNestedAttribute{
+		Optional: true,
+		Attributes: map[string]schema.Attribute{
+			"method": schema.StringAttribute{
+				Description:         "The HTTP method. Defaults to the one of the default route",
+				MarkdownDescription: "The HTTP method. Defaults to the one of the default route",
+				Optional:            true,
+			},
+			"path": schema.StringAttribute{
+				Description:         "The path template relative to the base URL, where {id} is replaced by the id of the resource. It can carry a query string, e.g. {id}?expand=true",
+				MarkdownDescription: "The path template relative to the base URL, where `{id}` is replaced by the id of the resource. It can carry a query string, e.g. `{id}?expand=true`",
+				Required:            true,
+			},
+			"status_codes": schema.ListAttribute{
+				ElementType:         types.Int64Type,
+				Description:         "The status codes of the successful responses. Defaults to the ones of the default route",
+				MarkdownDescription: "The status codes of the successful responses. Defaults to the ones of the default route",
+				Optional:            true,
+			},
+		},
+		Description:         fmt.Sprintf("The route %s. Defaults to %s", operation, defaults),
+		MarkdownDescription: fmt.Sprintf("The route %s. Defaults to %s", operation, defaults),
+	}
+}
+
+// rateLimitAttribute returns the attribute throttling the operations of a backend.
+func rateLimitAttribute() schema.SingleNestedAttribute {
+	return schema.SingleNestedAttribute{
+		Optional: true,
+		Attributes: map[string]schema.Attribute{
+			"requests_per_second": schema.Float64Attribute{
+				Description:         "The rate of the operations allowed in the long run. Defaults to no rate limit",
+				MarkdownDescription: "The rate of the operations allowed in the long run. Defaults to no rate limit",
+				Optional:            true,
+			},
+			"burst": schema.Int64Attribute{
+				Description:         "The number of the operations allowed at once, beyond the rate. Defaults to 1",
+				MarkdownDescription: "The number of the operations allowed at once, beyond the rate. Defaults to `1`",
+				Optional:            true,
+			},
+			"max_in_flight": schema.Int64Attribute{
+				Description:         "The maximum number of the concurrent operations. Defaults to no limit",
+				MarkdownDescription: "The maximum number of the concurrent operations. Defaults to no limit",
+				Optional:            true,
+			},
+		},
+		Description:         "Throttle the operations on the backend service, which are admitted in the order they arrive",
+		MarkdownDescription: "Throttle the operations on the backend service, which are admitted in the order they arrive",
+	}
+}
+
+// withHTTPAttributes returns the attributes together with the ones shared by the HTTP based backends.
+func withHTTPAttributes(attrs map[string]schema.Attribute) map[string]schema.Attribute {
+	for k, v := range httpAttributes() {
+		attrs[k] = v
+	}
+	return attrs
+}
+
 func (p *Provider) ValidateConfig(ctx context.Context, req provider.ValidateConfigRequest, resp *provider.ValidateConfigResponse) {
 	var config providerData
 	diags := req.Config.Get(ctx, &config)
@@ -79,17 +799,37 @@ func (p *Provider) ValidateConfig(ctx context.Context, req provider.ValidateConf
 	if diags.HasError() {
 		return
 	}
-	if config.FileSystem.IsNull() && config.JSONServer.IsNull() {
+	backends := []struct {
+		name  string
+		value types.Object
+	}{
+		{"filesystem", config.FileSystem},
+		{"jsonserver", config.JSONServer},
+		{"logstore", config.LogStore},
+		{"memory", config.Memory},
+		{"rest", config.REST},
+		{"git", config.Git},
+		{"s3", config.S3},
+	}
+	var names []string
+	var specified int
+	for _, backend := range backends {
+		names = append(names, strconv.Quote(backend.name))
+		if !backend.value.IsNull() {
+			specified++
+		}
+	}
+	if specified == 0 {
 		resp.Diagnostics.AddError(
 			"Invalid configuration",
-			`None of "filesystem" and "jsonserver" is specified`,
+			fmt.Sprintf("None of %s is specified", strings.Join(names, ", ")),
 		)
 		return
 	}
-	if !config.FileSystem.IsNull() && !config.JSONServer.IsNull() {
+	if specified > 1 {
 		resp.Diagnostics.AddError(
 			"Invalid configuration",
-			`Only one of "filesystem" and "jsonserver" can be specified`,
+			fmt.Sprintf("Only one of %s can be specified", strings.Join(names, ", ")),
 		)
 		return
 	}
@@ -104,6 +844,16 @@ func (p *Provider) Configure(ctx context.Context, req provider.ConfigureRequest,
 		return
 	}
 
+	wireLog, diags := expandWireLogOption(ctx, config.WireLog)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+
+	var (
+		rateLimit types.Object
+		backend   string
+	)
 	switch {
 	case !config.FileSystem.IsNull():
 		var fs filesystemData
@@ -112,7 +862,19 @@ func (p *Provider) Configure(ctx context.Context, req provider.ConfigureRequest,
 		if diags.HasError() {
 			return
 		}
-		client, err := client.NewFsClient(fs.Workdir.ValueString())
+		rateLimit, backend = fs.RateLimit, "filesystem"
+		p.collections = fs.TypeSubdirectory.ValueBool()
+		opt, diags := expandFsClientOption(ctx, fs)
+		resp.Diagnostics.Append(diags...)
+		if diags.HasError() {
+			return
+		}
+		opt.WireLog = wireLog
+		if p.collections {
+			// The json files written before the type subdirectories are used are all demo_foo resources.
+			opt.LegacyCollection = fooCollection
+		}
+		client, err := client.NewFsClient(fs.Workdir.ValueString(), opt)
 		if err != nil {
 			resp.Diagnostics.AddError(
 				"Failed to new filesystem client",
@@ -122,12 +884,34 @@ func (p *Provider) Configure(ctx context.Context, req provider.ConfigureRequest,
 		p.client = client
 	case !config.JSONServer.IsNull():
 		var jsonserver jsonserverData
-		diags := config.FileSystem.As(ctx, &jsonserver, basetypes.ObjectAsOptions{})
+		diags := objectAttributesAs(ctx, config.JSONServer, &jsonserver)
 		resp.Diagnostics.Append(diags...)
 		if diags.HasError() {
 			return
 		}
-		client, err := client.NewJSONServerClient(jsonserver.URL.ValueString())
+		rateLimit, backend = jsonserver.RateLimit, "jsonserver"
+		if jsonserver.URL.IsNull() == jsonserver.BaseURL.IsNull() {
+			resp.Diagnostics.AddAttributeError(path.Root("jsonserver").AtName("url"), "Invalid configuration", "Exactly one of url and base_url has to be specified")
+			return
+		}
+		endpoint := jsonserver.URL.ValueString()
+		if !jsonserver.BaseURL.IsNull() {
+			endpoint, p.collections = jsonserver.BaseURL.ValueString(), true
+		}
+		h, diags := expandHTTPOption(ctx, path.Root("jsonserver"), config.JSONServer)
+		resp.Diagnostics.Append(diags...)
+		if diags.HasError() {
+			return
+		}
+		opt := client.JSONServerClientOption{
+			Retry:     h.Retry,
+			Transport: h.Transport,
+			Auth:      h.Auth,
+			Compress:  h.Compress,
+			IDField:   jsonserver.IDField.ValueString(),
+			WireLog:   wireLog,
+		}
+		client, err := client.NewJSONServerClient(endpoint, &opt)
 		if err != nil {
 			resp.Diagnostics.AddError(
 				"Failed to new jsonserver client",
@@ -135,13 +919,661 @@ func (p *Provider) Configure(ctx context.Context, req provider.ConfigureRequest,
 			)
 		}
 		p.client = client
+	case !config.REST.IsNull():
+		var rest restData
+		diags := objectAttributesAs(ctx, config.REST, &rest)
+		resp.Diagnostics.Append(diags...)
+		if diags.HasError() {
+			return
+		}
+		rateLimit, backend = rest.RateLimit, "rest"
+		h, diags := expandHTTPOption(ctx, path.Root("rest"), config.REST)
+		resp.Diagnostics.Append(diags...)
+		if diags.HasError() {
+			return
+		}
+		opt, diags := expandRESTClientOption(ctx, rest)
+		resp.Diagnostics.Append(diags...)
+		if diags.HasError() {
+			return
+		}
+		opt.Retry = h.Retry
+		opt.Transport = h.Transport
+		opt.Auth = h.Auth
+		opt.Compress = h.Compress
+		opt.WireLog = wireLog
+		client, err := client.NewRESTClient(rest.URL.ValueString(), opt)
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Failed to new rest client",
+				err.Error(),
+			)
+		}
+		p.client = client
+	case !config.LogStore.IsNull():
+		var logstore logstoreData
+		diags := config.LogStore.As(ctx, &logstore, basetypes.ObjectAsOptions{})
+		resp.Diagnostics.Append(diags...)
+		if diags.HasError() {
+			return
+		}
+		rateLimit, backend = logstore.RateLimit, "logstore"
+		opt := client.LogStoreClientOption{
+			CompactionRatio:   logstore.CompactionRatio.ValueFloat64(),
+			CompactionMinSize: logstore.CompactionMinSize.ValueInt64(),
+		}
+		if !logstore.LockTimeout.IsNull() {
+			d, err := time.ParseDuration(logstore.LockTimeout.ValueString())
+			if err != nil {
+				resp.Diagnostics.AddAttributeError(path.Root("logstore").AtName("lock_timeout"), "Invalid duration", err.Error())
+				return
+			}
+			opt.LockTimeout = d
+		}
+		client, err := client.NewLogStoreClient(logstore.Path.ValueString(), &opt)
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Failed to new logstore client",
+				err.Error(),
+			)
+		}
+		p.client = client
+	case !config.Git.IsNull():
+		var git gitData
+		diags := config.Git.As(ctx, &git, basetypes.ObjectAsOptions{})
+		resp.Diagnostics.Append(diags...)
+		if diags.HasError() {
+			return
+		}
+		rateLimit, backend = git.RateLimit, "git"
+		opt := client.GitClientOption{
+			Extension:   git.Extension.ValueString(),
+			Pretty:      git.Pretty.ValueBool(),
+			AuthorName:  git.AuthorName.ValueString(),
+			AuthorEmail: git.AuthorEmail.ValueString(),
+			Branch:      git.Branch.ValueString(),
+			Command:     git.Command.ValueString(),
+			WireLog:     wireLog,
+		}
+		if !git.LockTimeout.IsNull() {
+			d, err := time.ParseDuration(git.LockTimeout.ValueString())
+			if err != nil {
+				resp.Diagnostics.AddAttributeError(path.Root("git").AtName("lock_timeout"), "Invalid duration", err.Error())
+				return
+			}
+			opt.LockTimeout = d
+		}
+		client, err := client.NewGitClient(git.Workdir.ValueString(), &opt)
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Failed to new git client",
+				err.Error(),
+			)
+		}
+		p.client = client
+	case !config.S3.IsNull():
+		var s3 s3Data
+		diags := config.S3.As(ctx, &s3, basetypes.ObjectAsOptions{})
+		resp.Diagnostics.Append(diags...)
+		if diags.HasError() {
+			return
+		}
+		rateLimit, backend = s3.RateLimit, "s3"
+		opt, diags := expandS3ClientOption(ctx, s3)
+		resp.Diagnostics.Append(diags...)
+		if diags.HasError() {
+			return
+		}
+		opt.WireLog = wireLog
+		client, err := client.NewS3Client(s3.Bucket.ValueString(), opt)
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Failed to new s3 client",
+				err.Error(),
+			)
+		}
+		p.client = client
+	case !config.Memory.IsNull():
+		var memory memoryData
+		diags := config.Memory.As(ctx, &memory, basetypes.ObjectAsOptions{})
+		resp.Diagnostics.Append(diags...)
+		if diags.HasError() {
+			return
+		}
+		rateLimit, backend = memory.RateLimit, "memory"
+		name := "default"
+		if !memory.Name.IsNull() {
+			name = memory.Name.ValueString()
+		}
+		p.client = client.SharedMemoryClient(name)
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var middlewares []client.Middleware
+	if !config.Middleware.IsNull() {
+		var middleware middlewareData
+		diags := config.Middleware.As(ctx, &middleware, basetypes.ObjectAsOptions{})
+		resp.Diagnostics.Append(diags...)
+		if diags.HasError() {
+			return
+		}
+		middlewares, diags = expandMiddlewares(ctx, middleware)
+		resp.Diagnostics.Append(diags...)
+		if diags.HasError() {
+			return
+		}
 	}
+	if !rateLimit.IsNull() {
+		opt, diags := expandRateLimitOption(ctx, path.Root(backend).AtName("rate_limit"), rateLimit)
+		resp.Diagnostics.Append(diags...)
+		if diags.HasError() {
+			return
+		}
+		// The rate limit is the innermost, so that the other middlewares see the time waiting for the admission.
+		middlewares = append(middlewares, client.RateLimitMiddleware(*opt))
+	}
+	p.middlewares = middlewares
+	p.client = client.Chain(p.client, middlewares...)
+	p.collectionClients = nil
 
 	resp.ResourceData = p
+	resp.DataSourceData = p
+}
+
+// collection returns the client of the collection of the resource type, which is wrapped by the same middlewares as
+// the client. It is the client itself if the backend keeps all the resources together.
+func (p *Provider) collection(name string) (client.Client, error) {
+	if !p.collections {
+		return p.client, nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if c, ok := p.collectionClients[name]; ok {
+		return c, nil
+	}
+	cc, ok := client.Unwrap(p.client).(client.CollectionClient)
+	if !ok {
+		return nil, fmt.Errorf("the backend doesn't support the collections")
+	}
+	c, err := cc.Collection(name)
+	if err != nil {
+		return nil, err
+	}
+	c = client.Chain(c, p.middlewares...)
+	if p.collectionClients == nil {
+		p.collectionClients = map[string]client.Client{}
+	}
+	p.collectionClients[name] = c
+	return c, nil
+}
+
+func expandFsClientOption(ctx context.Context, data filesystemData) (*client.FsClientOption, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	opt := &client.FsClientOption{
+		Extension:   data.Extension.ValueString(),
+		ShardLength: int(data.ShardLength.ValueInt64()),
+		Pretty:      data.Pretty.ValueBool(),
+		Compress:    data.Compress.ValueBool(),
+
+		HistoryLimit: int(data.HistoryLimit.ValueInt64()),
+	}
+	if !data.LockTimeout.IsNull() {
+		d, err := time.ParseDuration(data.LockTimeout.ValueString())
+		if err != nil {
+			diags.AddAttributeError(path.Root("filesystem").AtName("lock_timeout"), "Invalid duration", err.Error())
+			return nil, diags
+		}
+		opt.LockTimeout = d
+	}
+	if !data.TrashRetention.IsNull() {
+		d, err := time.ParseDuration(data.TrashRetention.ValueString())
+		if err != nil {
+			diags.AddAttributeError(path.Root("filesystem").AtName("trash_retention"), "Invalid duration", err.Error())
+			return nil, diags
+		}
+		opt.TrashRetention = d
+	}
+	for _, mode := range []struct {
+		name  string
+		value types.String
+		mode  *os.FileMode
+	}{
+		{"file_mode", data.FileMode, &opt.FileMode},
+		{"dir_mode", data.DirMode, &opt.DirMode},
+	} {
+		if mode.value.IsNull() {
+			continue
+		}
+		v, err := strconv.ParseUint(mode.value.ValueString(), 8, 32)
+		if err != nil || v > 0777 {
+			diags.AddAttributeError(path.Root("filesystem").AtName(mode.name), "Invalid permission", fmt.Sprintf("%q is not a valid permission in octal", mode.value.ValueString()))
+			return nil, diags
+		}
+		*mode.mode = os.FileMode(v)
+	}
+	if !data.Encryption.IsNull() {
+		encryption, d := expandEncryptionOption(ctx, data.Encryption)
+		diags.Append(d...)
+		if diags.HasError() {
+			return nil, diags
+		}
+		opt.Encryption = encryption
+	}
+	return opt, diags
+}
+
+func expandEncryptionOption(ctx context.Context, obj types.Object) (*client.EncryptionOption, diag.Diagnostics) {
+	var data encryptionData
+	diags := obj.As(ctx, &data, basetypes.ObjectAsOptions{})
+	if diags.HasError() {
+		return nil, diags
+	}
+	root := path.Root("filesystem").AtName("encryption")
+	opt := &client.EncryptionOption{
+		KeyID: data.KeyID.ValueString(),
+	}
+
+	var (
+		encoded string
+		keyPath = root.AtName("key")
+	)
+	switch {
+	case !data.Key.IsNull() && !data.KeyFile.IsNull():
+		diags.AddAttributeError(keyPath, "Conflicting attributes", "Only one of key and key_file can be specified")
+		return nil, diags
+	case !data.Key.IsNull():
+		encoded = data.Key.ValueString()
+	case !data.KeyFile.IsNull():
+		keyPath = root.AtName("key_file")
+		b, err := os.ReadFile(data.KeyFile.ValueString())
+		if err != nil {
+			diags.AddAttributeError(keyPath, "Failed to read the key file", err.Error())
+			return nil, diags
+		}
+		encoded = string(b)
+	default:
+		encoded = os.Getenv(EnvFsEncryptionKey)
+		if encoded == "" {
+			diags.AddAttributeError(keyPath, "Missing encryption key", fmt.Sprintf("One of key, key_file or the environment variable %q has to be set", EnvFsEncryptionKey))
+			return nil, diags
+		}
+	}
+	key, err := client.DecodeEncryptionKey(encoded)
+	if err != nil {
+		diags.AddAttributeError(keyPath, "Invalid encryption key", err.Error())
+		return nil, diags
+	}
+	opt.Key = key
+
+	if !data.PreviousKeys.IsNull() {
+		var previousKeys map[string]string
+		if diags := data.PreviousKeys.ElementsAs(ctx, &previousKeys, false); diags.HasError() {
+			return nil, diags
+		}
+		opt.PreviousKeys = map[string][]byte{}
+		for id, encoded := range previousKeys {
+			key, err := client.DecodeEncryptionKey(encoded)
+			if err != nil {
+				diags.AddAttributeError(root.AtName("previous_keys").AtMapKey(id), "Invalid encryption key", err.Error())
+				return nil, diags
+			}
+			opt.PreviousKeys[id] = key
+		}
+	}
+	return opt, diags
+}
+
+// httpOption is the options shared by the HTTP based clients.
+type httpOption struct {
+	Retry     *client.RetryOption
+	Transport *client.TransportOption
+	Auth      *client.AuthOption
+	Compress  bool
+}
+
+func expandHTTPOption(ctx context.Context, root path.Path, obj types.Object) (*httpOption, diag.Diagnostics) {
+	var data httpData
+	diags := objectAttributesAs(ctx, obj, &data)
+	if diags.HasError() {
+		return nil, diags
+	}
+	opt := &httpOption{
+		Compress: data.Compress.ValueBool(),
+	}
+	if !data.Retry.IsNull() {
+		retry, d := expandRetryOption(ctx, root.AtName("retry"), data.Retry)
+		diags.Append(d...)
+		if diags.HasError() {
+			return nil, diags
+		}
+		opt.Retry = retry
+	}
+	transport, d := expandTransportOption(root, data)
+	diags.Append(d...)
+	if diags.HasError() {
+		return nil, diags
+	}
+	opt.Transport = transport
+	auth, d := expandAuthOption(ctx, data)
+	diags.Append(d...)
+	if diags.HasError() {
+		return nil, diags
+	}
+	opt.Auth = auth
+	return opt, diags
+}
+
+func expandRetryOption(ctx context.Context, root path.Path, obj types.Object) (*client.RetryOption, diag.Diagnostics) {
+	var data retryData
+	diags := obj.As(ctx, &data, basetypes.ObjectAsOptions{})
+	if diags.HasError() {
+		return nil, diags
+	}
+	opt := client.DefaultRetryOption()
+	if !data.MaxAttempts.IsNull() {
+		opt.MaxAttempts = int(data.MaxAttempts.ValueInt64())
+	}
+	if !data.BaseBackoff.IsNull() {
+		d, err := time.ParseDuration(data.BaseBackoff.ValueString())
+		if err != nil {
+			diags.AddAttributeError(root.AtName("base_backoff"), "Invalid duration", err.Error())
+			return nil, diags
+		}
+		opt.BaseBackoff = d
+	}
+	if !data.MaxBackoff.IsNull() {
+		d, err := time.ParseDuration(data.MaxBackoff.ValueString())
+		if err != nil {
+			diags.AddAttributeError(root.AtName("max_backoff"), "Invalid duration", err.Error())
+			return nil, diags
+		}
+		opt.MaxBackoff = d
+	}
+	if !data.Jitter.IsNull() {
+		opt.Jitter = data.Jitter.ValueBool()
+	}
+	return &opt, diags
+}
+
+func expandTransportOption(root path.Path, data httpData) (*client.TransportOption, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	opt := &client.TransportOption{
+		ProxyURL: data.ProxyURL.ValueString(),
+		TLS: &client.TLSOption{
+			CAFile:             data.CAFile.ValueString(),
+			CAPEM:              data.CAPEM.ValueString(),
+			ClientCertFile:     data.ClientCertFile.ValueString(),
+			ClientCertPEM:      data.ClientCertPEM.ValueString(),
+			ClientKeyFile:      data.ClientKeyFile.ValueString(),
+			ClientKeyPEM:       data.ClientKeyPEM.ValueString(),
+			InsecureSkipVerify: data.InsecureSkipVerify.ValueBool(),
+		},
+	}
+	if !data.Timeout.IsNull() {
+		d, err := time.ParseDuration(data.Timeout.ValueString())
+		if err != nil {
+			diags.AddAttributeError(root.AtName("timeout"), "Invalid duration", err.Error())
+			return nil, diags
+		}
+		opt.Timeout = d
+	}
+	return opt, diags
+}
+
+func expandAuthOption(ctx context.Context, data httpData) (*client.AuthOption, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	opt := &client.AuthOption{
+		BearerToken: data.BearerToken.ValueString(),
+	}
+	if !data.BasicAuth.IsNull() {
+		var basicAuth basicAuthData
+		diags.Append(data.BasicAuth.As(ctx, &basicAuth, basetypes.ObjectAsOptions{})...)
+		if diags.HasError() {
+			return nil, diags
+		}
+		opt.Username = basicAuth.Username.ValueString()
+		opt.Password = basicAuth.Password.ValueString()
+	}
+	if !data.APIKey.IsNull() {
+		var apiKey apiKeyData
+		diags.Append(data.APIKey.As(ctx, &apiKey, basetypes.ObjectAsOptions{})...)
+		if diags.HasError() {
+			return nil, diags
+		}
+		opt.APIKeyHeader = apiKey.Header.ValueString()
+		opt.APIKey = apiKey.Value.ValueString()
+	}
+	if !data.Headers.IsNull() {
+		diags.Append(data.Headers.ElementsAs(ctx, &opt.Headers, false)...)
+		if diags.HasError() {
+			return nil, diags
+		}
+	}
+	if !data.CredentialHelper.IsNull() {
+		var helper credentialHelperData
+		diags.Append(data.CredentialHelper.As(ctx, &helper, basetypes.ObjectAsOptions{})...)
+		if diags.HasError() {
+			return nil, diags
+		}
+		opt.CredentialHelper = &client.CredentialHelperOption{
+			Command: helper.Command.ValueString(),
+		}
+		if !helper.Args.IsNull() {
+			diags.Append(helper.Args.ElementsAs(ctx, &opt.CredentialHelper.Args, false)...)
+			if diags.HasError() {
+				return nil, diags
+			}
+		}
+	}
+	return opt, diags
+}
+
+func expandRESTClientOption(ctx context.Context, data restData) (*client.RESTClientOption, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	opt := &client.RESTClientOption{
+		IDPath:         data.IDPath.ValueString(),
+		IDFromLocation: data.IDFromLocation.ValueBool(),
+		ListItemsPath:  data.ListItemsPath.ValueString(),
+		ListItemIDPath: data.ListItemIDPath.ValueString(),
+	}
+	for _, route := range []struct {
+		obj   types.Object
+		route **client.RESTRoute
+	}{
+		{data.Create, &opt.Create},
+		{data.Read, &opt.Read},
+		{data.Update, &opt.Update},
+		{data.Patch, &opt.Patch},
+		{data.Delete, &opt.Delete},
+		{data.List, &opt.List},
+	} {
+		if route.obj.IsNull() {
+			continue
+		}
+		var routeData restRouteData
+		if diags := route.obj.As(ctx, &routeData, basetypes.ObjectAsOptions{}); diags.HasError() {
+			return nil, diags
+		}
+		r := &client.RESTRoute{
+			Method: strings.ToUpper(routeData.Method.ValueString()),
+			Path:   routeData.Path.ValueString(),
+		}
+		if !routeData.StatusCodes.IsNull() {
+			var codes []int64
+			if diags := routeData.StatusCodes.ElementsAs(ctx, &codes, false); diags.HasError() {
+				return nil, diags
+			}
+			for _, code := range codes {
+				r.StatusCodes = append(r.StatusCodes, int(code))
+			}
+		}
+		*route.route = r
+	}
+	return opt, diags
+}
+
+func expandS3ClientOption(ctx context.Context, data s3Data) (*client.S3ClientOption, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	root := path.Root("s3")
+	opt := &client.S3ClientOption{
+		Endpoint:          data.Endpoint.ValueString(),
+		Region:            data.Region.ValueString(),
+		Prefix:            data.Prefix.ValueString(),
+		PathStyle:         data.PathStyle.ValueBool(),
+		AccessKeyID:       data.AccessKeyID.ValueString(),
+		SecretAccessKey:   data.SecretAccessKey.ValueString(),
+		SessionToken:      data.SessionToken.ValueString(),
+		ConditionalWrites: data.ConditionalWrites.ValueBool(),
+	}
+	for _, v := range []struct {
+		value *string
+		env   string
+	}{
+		{&opt.Region, EnvAWSRegion},
+		{&opt.AccessKeyID, EnvAWSAccessKeyID},
+		{&opt.SecretAccessKey, EnvAWSSecretAccessKey},
+		{&opt.SessionToken, EnvAWSSessionToken},
+	} {
+		if *v.value == "" {
+			*v.value = os.Getenv(v.env)
+		}
+	}
+	if !data.Retry.IsNull() {
+		retry, diags := expandRetryOption(ctx, root.AtName("retry"), data.Retry)
+		if diags.HasError() {
+			return nil, diags
+		}
+		opt.Retry = retry
+	}
+	if !data.Timeout.IsNull() {
+		d, err := time.ParseDuration(data.Timeout.ValueString())
+		if err != nil {
+			diags.AddAttributeError(root.AtName("timeout"), "Invalid duration", err.Error())
+			return nil, diags
+		}
+		opt.Transport = &client.TransportOption{Timeout: d}
+	}
+	return opt, diags
+}
+
+func expandRateLimitOption(ctx context.Context, root path.Path, obj types.Object) (*client.RateLimitOption, diag.Diagnostics) {
+	var data rateLimitData
+	diags := obj.As(ctx, &data, basetypes.ObjectAsOptions{})
+	if diags.HasError() {
+		return nil, diags
+	}
+	opt := &client.RateLimitOption{
+		RequestsPerSecond: data.RequestsPerSecond.ValueFloat64(),
+		Burst:             int(data.Burst.ValueInt64()),
+		MaxInFlight:       int(data.MaxInFlight.ValueInt64()),
+	}
+	for _, v := range []struct {
+		name     string
+		negative bool
+	}{
+		{"requests_per_second", opt.RequestsPerSecond < 0},
+		{"burst", opt.Burst < 0},
+		{"max_in_flight", opt.MaxInFlight < 0},
+	} {
+		if v.negative {
+			diags.AddAttributeError(root.AtName(v.name), "Invalid value", "The value can't be negative")
+			return nil, diags
+		}
+	}
+	return opt, diags
+}
+
+func expandWireLogOption(ctx context.Context, obj types.Object) (*client.WireLogOption, diag.Diagnostics) {
+	if obj.IsNull() {
+		return nil, nil
+	}
+	var data wireLogData
+	diags := obj.As(ctx, &data, basetypes.ObjectAsOptions{})
+	if diags.HasError() {
+		return nil, diags
+	}
+	opt := &client.WireLogOption{}
+	if !data.RedactFields.IsNull() {
+		opt.RedactFields = []string{}
+		diags.Append(data.RedactFields.ElementsAs(ctx, &opt.RedactFields, false)...)
+	}
+	if !data.RedactHeaders.IsNull() {
+		diags.Append(data.RedactHeaders.ElementsAs(ctx, &opt.RedactHeaders, false)...)
+	}
+	if diags.HasError() {
+		return nil, diags
+	}
+	return opt, diags
+}
+
+// expandMiddlewares returns the enabled middlewares, from the outermost to the innermost.
+func expandMiddlewares(ctx context.Context, data middlewareData) ([]client.Middleware, diag.Diagnostics) {
+	var (
+		middlewares []client.Middleware
+		diags       diag.Diagnostics
+	)
+	if data.Logging.ValueBool() {
+		middlewares = append(middlewares, client.LoggingMiddleware(func(ctx context.Context, msg string, fields map[string]interface{}) {
+			tflog.Debug(ctx, msg, fields)
+		}))
+	}
+	if data.Timing.ValueBool() {
+		middlewares = append(middlewares, client.TimingMiddleware(func(ctx context.Context, op client.Operation, d time.Duration, err error) {
+			tflog.Debug(ctx, "client operation timing", map[string]interface{}{
+				"operation":   op.Name,
+				"id":          op.ID,
+				"duration_ms": d.Milliseconds(),
+				"failed":      err != nil,
+			})
+		}))
+	}
+	if !data.Cache.IsNull() {
+		var cache cacheData
+		diags.Append(data.Cache.As(ctx, &cache, basetypes.ObjectAsOptions{})...)
+		if diags.HasError() {
+			return nil, diags
+		}
+		threshold := cache.PrefetchThreshold.ValueInt64()
+		if threshold < 0 {
+			diags.AddAttributeError(path.Root("middleware").AtName("cache").AtName("prefetch_threshold"), "Invalid value", "The value can't be negative")
+			return nil, diags
+		}
+		// The cache is inside the logging and the timing, so that the cache hits are seen by them.
+		middlewares = append(middlewares, client.CacheMiddleware(client.CacheOption{
+			PrefetchThreshold: int(threshold),
+		}))
+	}
+	return middlewares, diags
+}
+
+// objectAttributesAs is like the types.Object.As, but only decodes the attributes tagged in the target struct, which
+// allows the attributes shared by several blocks to be decoded into the same struct.
+func objectAttributesAs(ctx context.Context, obj types.Object, target interface{}) diag.Diagnostics {
+	attrs := obj.Attributes()
+	attrTypes := obj.AttributeTypes(ctx)
+	subAttrs := map[string]attr.Value{}
+	subAttrTypes := map[string]attr.Type{}
+	typ := reflect.TypeOf(target).Elem()
+	for i := 0; i < typ.NumField(); i++ {
+		name := typ.Field(i).Tag.Get("tfsdk")
+		subAttrs[name] = attrs[name]
+		subAttrTypes[name] = attrTypes[name]
+	}
+	sub, diags := types.ObjectValue(subAttrTypes, subAttrs)
+	if diags.HasError() {
+		return diags
+	}
+	return sub.As(ctx, target, basetypes.ObjectAsOptions{})
 }
 
 func (*Provider) DataSources(context.Context) []func() datasource.DataSource {
-	return nil
+	return []func() datasource.DataSource{
+		func() datasource.DataSource {
+			return &dataSourceFooHistory{}
+		},
+	}
 }
 
 func (*Provider) Resources(context.Context) []func() resource.Resource {
@@ -149,5 +1581,8 @@ func (*Provider) Resources(context.Context) []func() resource.Resource {
 		func() resource.Resource {
 			return &resourceFoo{}
 		},
+		func() resource.Resource {
+			return &resourceFooRestore{}
+		},
 	}
 }
@@ -3,10 +3,12 @@ package demo
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 
 	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
@@ -16,8 +18,11 @@ import (
 	"github.com/magodo/terraform-provider-demo/client"
 )
 
+// fooCollection is the collection of the backend storing the foo resources.
+const fooCollection = "foo"
+
 type resourceFoo struct {
-	p *Provider
+	client client.Client
 }
 
 type fooData struct {
@@ -112,7 +117,15 @@ func (r *resourceFoo) Configure(ctx context.Context, req resource.ConfigureReque
 		)
 		return
 	}
-	r.p = provider
+	c, err := provider.collection(fooCollection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to get the client of the collection",
+			fmt.Sprintf("Getting the client of the collection %q: %v", fooCollection, err),
+		)
+		return
+	}
+	r.client = c
 }
 
 // Create is called when the provider must create a new resource. Config
@@ -127,55 +140,14 @@ func (r resourceFoo) Create(ctx context.Context, req resource.CreateRequest, res
 		return
 	}
 
-	// Expand
-	m := map[string]interface{}{}
-	if !plan.String.IsNull() {
-		m["string"] = plan.String.ValueString()
-	}
-	if !plan.Int64.IsNull() {
-		m["int64"] = plan.Int64.ValueInt64()
-	}
-	if !plan.Float64.IsNull() {
-		m["float64"] = plan.Float64.ValueFloat64()
-	}
-	if !plan.Number.IsNull() {
-		m["number"], _ = plan.Number.ValueBigFloat().Float64()
-	}
-	if !plan.Bool.IsNull() {
-		m["bool"] = plan.Bool.ValueBool()
-	}
-	if !plan.ListNestedBlock.IsNull() {
-		var blks []nestedData
-		diags := plan.ListNestedBlock.ElementsAs(ctx, &blks, false)
-		resp.Diagnostics.Append(diags...)
-		if diags.HasError() {
-			return
-		}
-		m["list_nested_block"] = expandNestedObject(blks)
-	}
-	if !plan.SetNestedBlock.IsNull() {
-		var blks []nestedData
-		diags := plan.SetNestedBlock.ElementsAs(ctx, &blks, false)
-		resp.Diagnostics.Append(diags...)
-		if diags.HasError() {
-			return
-		}
-		m["set_nested_block"] = expandNestedObject(blks)
-	}
-	b, err := json.Marshal(m)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Creation failure",
-			fmt.Sprintf("Failed to JSON encode the request: %v", err),
-		)
+	b, diags := expandFoo(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
 		return
 	}
-	id, err := r.p.client.Create(b)
+	id, err := r.client.Create(ctx, b)
 	if err != nil {
-		resp.Diagnostics.AddError(
-			"Creation failure",
-			fmt.Sprintf("Sending create request: %v", err),
-		)
+		addClientError(&resp.Diagnostics, "Creation failure", "Sending create request", err)
 		return
 	}
 	diags = resp.State.Set(ctx,
@@ -201,12 +173,14 @@ func (r resourceFoo) Create(ctx context.Context, req resource.CreateRequest, res
 	}
 	rresp := resource.ReadResponse{
 		State:       resp.State,
+		Private:     resp.Private,
 		Diagnostics: resp.Diagnostics,
 	}
 	r.Read(ctx, rreq, &rresp)
 
 	*resp = resource.CreateResponse{
 		State:       rresp.State,
+		Private:     rresp.Private,
 		Diagnostics: rresp.Diagnostics,
 	}
 }
@@ -222,16 +196,13 @@ func (r resourceFoo) Read(ctx context.Context, req resource.ReadRequest, resp *r
 	if diags.HasError() {
 		return
 	}
-	b, err := r.p.client.Read(state.ID.ValueString())
+	b, version, err := r.client.Read(ctx, state.ID.ValueString())
 	if err != nil {
-		if err == client.ErrNotFound {
+		if errors.Is(err, client.ErrNotFound) {
 			resp.State.RemoveResource(ctx)
 			return
 		}
-		resp.Diagnostics.AddError(
-			"Read failure",
-			fmt.Sprintf("Sending read request: %v", err),
-		)
+		addClientError(&resp.Diagnostics, "Read failure", "Sending read request", err)
 		return
 	}
 	var m map[string]interface{}
@@ -243,26 +214,32 @@ func (r resourceFoo) Read(ctx context.Context, req resource.ReadRequest, resp *r
 		return
 	}
 
+	diags = setPrivateVersion(ctx, resp.Private, version)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+
 	// Flatten
-	if v, ok := m["string"]; ok {
+	if v, ok := m["string"]; ok && v != nil {
 		state.String = types.StringValue(v.(string))
 	}
-	if v, ok := m["int64"]; ok {
+	if v, ok := m["int64"]; ok && v != nil {
 		state.Int64 = types.Int64Value(int64(v.(float64)))
 	}
-	if v, ok := m["float64"]; ok {
+	if v, ok := m["float64"]; ok && v != nil {
 		state.Float64 = types.Float64Value(v.(float64))
 	}
-	if v, ok := m["number"]; ok {
+	if v, ok := m["number"]; ok && v != nil {
 		state.Number = types.NumberValue(big.NewFloat(v.(float64)))
 	}
-	if v, ok := m["bool"]; ok {
+	if v, ok := m["bool"]; ok && v != nil {
 		state.Bool = types.BoolValue(v.(bool))
 	}
-	if v, ok := m["list_nested_block"]; ok {
+	if v, ok := m["list_nested_block"]; ok && v != nil {
 		state.ListNestedBlock = types.ListValueMust(types.ObjectType{AttrTypes: map[string]attr.Type{"name": types.StringType, "age": types.Int64Type}}, flattenNestedObject(v.([]interface{})))
 	}
-	if v, ok := m["set_nested_block"]; ok {
+	if v, ok := m["set_nested_block"]; ok && v != nil {
 		state.SetNestedBlock = types.SetValueMust(types.ObjectType{AttrTypes: map[string]attr.Type{"name": types.StringType, "age": types.Int64Type}}, flattenNestedObject(v.([]interface{})))
 	}
 	diags = resp.State.Set(ctx, state)
@@ -284,62 +261,42 @@ func (r resourceFoo) Update(ctx context.Context, req resource.UpdateRequest, res
 		return
 	}
 
-	// Expand
-	m := map[string]interface{}{}
-	if !plan.String.IsNull() {
-		m["string"] = plan.String.ValueString()
-	}
-	if !plan.Int64.IsNull() {
-		m["int64"] = plan.Int64.ValueInt64()
-	}
-	if !plan.Float64.IsNull() {
-		m["float64"] = plan.Float64.ValueFloat64()
-	}
-	if !plan.Number.IsNull() {
-		m["number"], _ = plan.Number.ValueBigFloat().Float64()
-	}
-	if !plan.Bool.IsNull() {
-		m["bool"] = plan.Bool.ValueBool()
+	var state fooData
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
 	}
-	if !plan.ListNestedBlock.IsNull() {
-		var blks []nestedData
-		diags := plan.ListNestedBlock.ElementsAs(ctx, &blks, false)
-		resp.Diagnostics.Append(diags...)
-		if diags.HasError() {
-			return
-		}
-		m["list_nested_block"] = expandNestedObject(blks)
+
+	// Only send the attributes changed between the prior state and the plan, so that the other members of the
+	// stored object are preserved.
+	planJSON, diags := expandFoo(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
 	}
-	if !plan.SetNestedBlock.IsNull() {
-		var blks []nestedData
-		diags := plan.SetNestedBlock.ElementsAs(ctx, &blks, false)
-		resp.Diagnostics.Append(diags...)
-		if diags.HasError() {
-			return
-		}
-		m["set_nested_block"] = expandNestedObject(blks)
+	stateJSON, diags := expandFoo(ctx, state)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
 	}
-	b, err := json.Marshal(m)
+	patch, err := client.CreateMergePatch(stateJSON, planJSON)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Update failure",
-			fmt.Sprintf("Failed to JSON encode the request: %v", err),
+			fmt.Sprintf("Failed to create the merge patch: %v", err),
 		)
 		return
 	}
 
-	var state fooData
-	diags = req.State.Get(ctx, &state)
+	version, diags := getPrivateVersion(ctx, req.Private)
 	resp.Diagnostics.Append(diags...)
 	if diags.HasError() {
 		return
 	}
 
-	if err := r.p.client.Update(state.ID.ValueString(), b); err != nil {
-		resp.Diagnostics.AddError(
-			"Update failure",
-			fmt.Sprintf("Sending update request: %v", err),
-		)
+	if err := r.client.Patch(ctx, state.ID.ValueString(), patch, version); err != nil {
+		addClientError(&resp.Diagnostics, "Update failure", "Sending update request", err)
 		return
 	}
 
@@ -349,12 +306,14 @@ func (r resourceFoo) Update(ctx context.Context, req resource.UpdateRequest, res
 	}
 	rresp := resource.ReadResponse{
 		State:       resp.State,
+		Private:     resp.Private,
 		Diagnostics: resp.Diagnostics,
 	}
 	r.Read(ctx, rreq, &rresp)
 
 	*resp = resource.UpdateResponse{
 		State:       rresp.State,
+		Private:     rresp.Private,
 		Diagnostics: rresp.Diagnostics,
 	}
 }
@@ -369,15 +328,18 @@ func (r resourceFoo) Delete(ctx context.Context, req resource.DeleteRequest, res
 		return
 	}
 
-	if err := r.p.client.Delete(state.ID.ValueString()); err != nil {
-		if err == client.ErrNotFound {
+	version, diags := getPrivateVersion(ctx, req.Private)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+
+	if err := r.client.Delete(ctx, state.ID.ValueString(), version); err != nil {
+		if errors.Is(err, client.ErrNotFound) {
 			resp.State.RemoveResource(ctx)
 			return
 		}
-		resp.Diagnostics.AddError(
-			"Delete failure",
-			fmt.Sprintf("Sending delete request: %v", err),
-		)
+		addClientError(&resp.Diagnostics, "Delete failure", "Sending delete request", err)
 		return
 	}
 
@@ -395,6 +357,90 @@ func (r resourceFoo) ImportState(ctx context.Context, req resource.ImportStateRe
 	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
 }
 
+// privateKeyVersion is the private state key that stores the version of the resource got from the last read. It is
+// used to make the update and deletion conditional, so that concurrent modifications won't be silently overwritten.
+const privateKeyVersion = "version"
+
+type privateState interface {
+	GetKey(ctx context.Context, key string) ([]byte, diag.Diagnostics)
+	SetKey(ctx context.Context, key string, value []byte) diag.Diagnostics
+}
+
+func getPrivateVersion(ctx context.Context, private privateState) (string, diag.Diagnostics) {
+	b, diags := private.GetKey(ctx, privateKeyVersion)
+	if diags.HasError() || len(b) == 0 {
+		return "", diags
+	}
+	var version string
+	if err := json.Unmarshal(b, &version); err != nil {
+		diags.AddError(
+			"Invalid private state",
+			fmt.Sprintf("Failed to JSON decode the version: %v", err),
+		)
+	}
+	return version, diags
+}
+
+func setPrivateVersion(ctx context.Context, private privateState, version string) diag.Diagnostics {
+	// The private state value must be valid JSON.
+	b, err := json.Marshal(version)
+	if err != nil {
+		var diags diag.Diagnostics
+		diags.AddError(
+			"Invalid private state",
+			fmt.Sprintf("Failed to JSON encode the version: %v", err),
+		)
+		return diags
+	}
+	return private.SetKey(ctx, privateKeyVersion, b)
+}
+
+// expandFoo returns the JSON document of the resource.
+func expandFoo(ctx context.Context, data fooData) ([]byte, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	m := map[string]interface{}{}
+	if !data.String.IsNull() {
+		m["string"] = data.String.ValueString()
+	}
+	if !data.Int64.IsNull() {
+		m["int64"] = data.Int64.ValueInt64()
+	}
+	if !data.Float64.IsNull() {
+		m["float64"] = data.Float64.ValueFloat64()
+	}
+	if !data.Number.IsNull() {
+		m["number"], _ = data.Number.ValueBigFloat().Float64()
+	}
+	if !data.Bool.IsNull() {
+		m["bool"] = data.Bool.ValueBool()
+	}
+	if !data.ListNestedBlock.IsNull() {
+		var blks []nestedData
+		diags.Append(data.ListNestedBlock.ElementsAs(ctx, &blks, false)...)
+		if diags.HasError() {
+			return nil, diags
+		}
+		m["list_nested_block"] = expandNestedObject(blks)
+	}
+	if !data.SetNestedBlock.IsNull() {
+		var blks []nestedData
+		diags.Append(data.SetNestedBlock.ElementsAs(ctx, &blks, false)...)
+		if diags.HasError() {
+			return nil, diags
+		}
+		m["set_nested_block"] = expandNestedObject(blks)
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		diags.AddError(
+			"Invalid resource",
+			fmt.Sprintf("Failed to JSON encode the resource: %v", err),
+		)
+		return nil, diags
+	}
+	return b, diags
+}
+
 func expandNestedObject(l []nestedData) []interface{} {
 	var output []interface{}
 
@@ -418,11 +464,11 @@ func flattenNestedObject(l []interface{}) []attr.Value {
 		m := v.(map[string]interface{})
 
 		name := types.StringNull()
-		if v, ok := m["name"]; ok {
+		if v, ok := m["name"]; ok && v != nil {
 			name = types.StringValue(v.(string))
 		}
 		age := types.Int64Null()
-		if v, ok := m["age"]; ok {
+		if v, ok := m["age"]; ok && v != nil {
 			age = types.Int64Value(int64(v.(float64)))
 		}
 
@@ -0,0 +1,132 @@
+package demo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/magodo/terraform-provider-demo/client"
+)
+
+type resourceFooRestore struct {
+	client client.Client
+}
+
+type fooRestoreData struct {
+	ID      types.String `tfsdk:"id"`
+	FooID   types.String `tfsdk:"foo_id"`
+	Version types.String `tfsdk:"version"`
+}
+
+var _ resource.ResourceWithConfigure = &resourceFooRestore{}
+
+// Metadata implements resource.Resource.
+func (*resourceFooRestore) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_foo_restore"
+}
+
+// Schema implements resource.Resource.
+func (*resourceFooRestore) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description:         "Restores a Foo, which might have been deleted, to a prior version once it is created, which requires the backend to keep the history. Destroying it does nothing to the Foo",
+		MarkdownDescription: "Restores a `demo_foo`, which might have been deleted, to a prior version once it is created, which requires the backend to keep the history. Destroying it does nothing to the `demo_foo`",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"foo_id": schema.StringAttribute{
+				Description:         "The id of the Foo to restore",
+				MarkdownDescription: "The id of the `demo_foo` to restore",
+				Required:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"version": schema.StringAttribute{
+				Description:         "The version to restore, as listed by the demo_foo_history data source. Changing it restores the Foo again",
+				MarkdownDescription: "The version to restore, as listed by the `demo_foo_history` data source. Changing it restores the `demo_foo` again",
+				Required:            true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+		},
+	}
+}
+
+func (r *resourceFooRestore) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	provider, ok := req.ProviderData.(*Provider)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Unexpected Resource Configure Type",
+			fmt.Sprintf("got: %T.", req.ProviderData),
+		)
+		return
+	}
+	c, err := provider.collection(fooCollection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Failed to get the client of the collection",
+			fmt.Sprintf("Getting the client of the collection %q: %v", fooCollection, err),
+		)
+		return
+	}
+	r.client = c
+}
+
+// Create implements resource.Resource.
+func (r *resourceFooRestore) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan fooRestoreData
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		return
+	}
+	hc, ok := r.client.(client.HistoryClient)
+	if !ok {
+		resp.Diagnostics.AddError(
+			"Restore failure",
+			"The configured backend doesn't keep the history of the resources",
+		)
+		return
+	}
+	if err := hc.Restore(ctx, plan.FooID.ValueString(), plan.Version.ValueString()); err != nil {
+		if errors.Is(err, client.ErrNotFound) {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("version"),
+				"Restore failure",
+				fmt.Sprintf("No version %q is found for %q", plan.Version.ValueString(), plan.FooID.ValueString()),
+			)
+			return
+		}
+		addClientError(&resp.Diagnostics, "Restore failure", "Restoring the version", err)
+		return
+	}
+	plan.ID = types.StringValue(plan.FooID.ValueString() + "/" + plan.Version.ValueString())
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+// Read implements resource.Resource. The restore is done once it is created, so there is nothing to read back.
+func (*resourceFooRestore) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+}
+
+// Update implements resource.Resource. It is never called, as any change of the attributes replaces the resource.
+func (*resourceFooRestore) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+}
+
+// Delete implements resource.Resource. The restored Foo is left as is.
+func (*resourceFooRestore) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+}
@@ -0,0 +1,118 @@
+package demo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/magodo/terraform-provider-demo/client"
+	"github.com/magodo/terraform-provider-demo/demo/acctest"
+)
+
+type FooRestore struct {
+	workdir string
+}
+
+// TestAccFooRestore_basic runs against its own filesystem backend keeping the history, no matter which backend is
+// selected by the environment variables.
+func TestAccFooRestore_basic(t *testing.T) {
+	r := FooRestore{workdir: t.TempDir()}
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: acctest.Providers(),
+		PreCheck:                 func() { acctest.PreCheck(t, nil) },
+		CheckDestroy:             r.isDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: r.foo("v1"),
+			},
+			{
+				Config: r.history("v2"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.demo_foo_history.test", "versions.#", "1"),
+					resource.TestCheckResourceAttr("data.demo_foo_history.test", "versions.0.content", `{"string":"v1"}`),
+					resource.TestCheckResourceAttr("data.demo_foo_history.test", "versions.0.deleted", "false"),
+				),
+			},
+			{
+				// The restored content differs from the configuration of the demo_foo.
+				Config:             r.restore("v2"),
+				ExpectNonEmptyPlan: true,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPair("demo_foo_restore.test", "foo_id", "demo_foo.test", "id"),
+				),
+			},
+			{
+				Config: r.restore("v1"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("demo_foo.test", "string", "v1"),
+					resource.TestCheckResourceAttr("data.demo_foo_history.test", "versions.#", "2"),
+					resource.TestCheckResourceAttr("data.demo_foo_history.test", "versions.0.content", `{"string":"v2"}`),
+				),
+			},
+		},
+	})
+}
+
+func (r FooRestore) isDestroy(s *terraform.State) error {
+	c, err := client.NewFsClient(r.workdir, nil)
+	if err != nil {
+		return err
+	}
+	for label, resource := range s.RootModule().Resources {
+		if resource.Type != "demo_foo" {
+			continue
+		}
+		if _, _, err := c.Read(context.Background(), resource.Primary.ID); !errors.Is(err, client.ErrNotFound) {
+			return fmt.Errorf("reading %s: %v", label, err)
+		}
+	}
+	return nil
+}
+
+func (r FooRestore) foo(value string) string {
+	return fmt.Sprintf(`
+terraform {
+  required_providers {
+    demo = {
+      source = "magodo/demo"
+    }
+  }
+}
+
+provider "demo" {
+  filesystem = {
+    workdir       = %q
+    history_limit = 5
+  }
+}
+
+resource "demo_foo" "test" {
+  string = %q
+}
+`, r.workdir, value)
+}
+
+func (r FooRestore) history(value string) string {
+	return fmt.Sprintf(`%s
+
+data "demo_foo_history" "test" {
+  id = demo_foo.test.id
+
+  depends_on = [demo_foo.test]
+}
+`, r.foo(value))
+}
+
+func (r FooRestore) restore(value string) string {
+	// The oldest version stays the same after the restore, which keeps the current version in the history.
+	return fmt.Sprintf(`%s
+
+resource "demo_foo_restore" "test" {
+  foo_id  = demo_foo.test.id
+  version = data.demo_foo_history.test.versions[length(data.demo_foo_history.test.versions) - 1].version
+}
+`, r.history(value))
+}